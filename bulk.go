@@ -0,0 +1,267 @@
+package lpmgt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBulkWorkers is the number of requests sent concurrently by bulk operations.
+const DefaultBulkWorkers = 4
+
+// ErrBulkInterrupted is returned when a bulk operation is stopped before all items are done.
+var ErrBulkInterrupted = errors.New("Bulk operation was interrupted")
+
+// BulkItem is a user a bulk operation acts on. Empty Result means it is not done yet.
+type BulkItem struct {
+	UserName string      `json:"username"`
+	User     *User       `json:"user,omitempty"`
+	Result   BatchResult `json:"result,omitempty"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+// Checkpoint is progress of a bulk operation saved after each item so that it can be resumed.
+type Checkpoint struct {
+	Operation string            `json:"operation"`
+	Options   map[string]string `json:"options,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Items     []BulkItem        `json:"items"`
+	path      string
+	mu        sync.Mutex
+}
+
+// NewCheckpoint returns Checkpoint of `operation` over `items` saved to `path`.
+// Checkpoint is never saved if `path` is empty.
+func NewCheckpoint(path, operation string, options map[string]string, items []BulkItem) *Checkpoint {
+	now := time.Now()
+	return &Checkpoint{
+		Operation: operation,
+		Options:   options,
+		StartedAt: now,
+		UpdatedAt: now,
+		Items:     items,
+		path:      path,
+	}
+}
+
+// LoadCheckpoint reads a checkpoint file written by an interrupted bulk operation.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	c.path = path
+	return c, nil
+}
+
+// Path returns the file the checkpoint is saved to.
+func (c *Checkpoint) Path() string {
+	return c.path
+}
+
+// Save writes the checkpoint to its file.
+func (c *Checkpoint) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+func (c *Checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+	b, err := IndentedJSON(c)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(c.path, b)
+}
+
+// Pending returns indexes of items not done yet, or of failed items if `retryFailed` is set.
+func (c *Checkpoint) Pending(retryFailed bool) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := []int{}
+	for i, item := range c.Items {
+		if (retryFailed && item.Result == Failed) || (!retryFailed && item.Result == "") {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
+
+// Record sets results of items at `indexes` and saves the checkpoint.
+func (c *Checkpoint) Record(indexes []int, results []UserResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for j, i := range indexes {
+		c.Items[i].Result = results[j].Result
+		c.Items[i].Reason = results[j].Reason
+	}
+	c.UpdatedAt = time.Now()
+	return c.save()
+}
+
+// Results returns outcome of each item. Items not done yet are reported as pending.
+func (c *Checkpoint) Results() []UserResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results := []UserResult{}
+	for _, item := range c.Items {
+		r := UserResult{UserName: item.UserName, Result: item.Result, Reason: item.Reason}
+		if r.Result == "" {
+			r.Result = "pending"
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// BulkProgress is reported after each chunk of a bulk operation.
+type BulkProgress struct {
+	Done   int
+	Failed int
+	Total  int
+}
+
+// BulkOptions controls how RunBulk processes items.
+type BulkOptions struct {
+	// Workers is the number of chunks processed concurrently.
+	Workers int
+	// ChunkSize is the number of items passed to a single call.
+	ChunkSize   int
+	RetryFailed bool
+	// Stop stops handing out chunks when it's closed. Chunks in flight are finished and recorded.
+	Stop     <-chan struct{}
+	Progress func(BulkProgress)
+}
+
+// RunBulk calls `do` with chunks of pending items of `c` by a bounded pool of workers,
+// recording the results to the checkpoint after each chunk.
+// `do` is called concurrently and has to return a result for each item.
+func RunBulk(c *Checkpoint, opts BulkOptions, do func(items []BulkItem) []UserResult) error {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultBulkWorkers
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+
+	pending := c.Pending(opts.RetryFailed)
+	chunks := [][]int{}
+	for start := 0; start < len(pending); start += chunkSize {
+		end := start + chunkSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		chunks = append(chunks, pending[start:end])
+	}
+
+	var (
+		mu       sync.Mutex
+		progress = BulkProgress{Total: len(pending)}
+		saveErr  error
+		wg       sync.WaitGroup
+	)
+	aborted := make(chan struct{})
+	jobs := make(chan []int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for indexes := range jobs {
+				items := []BulkItem{}
+				c.mu.Lock()
+				for _, i := range indexes {
+					items = append(items, c.Items[i])
+				}
+				c.mu.Unlock()
+
+				results := do(items)
+				err := c.Record(indexes, results)
+
+				mu.Lock()
+				if err != nil && saveErr == nil {
+					saveErr = fmt.Errorf("Failed saving checkpoint %v: %v", c.path, err)
+					close(aborted)
+				}
+				progress.Done += len(results)
+				for _, r := range results {
+					if r.Result == Failed {
+						progress.Failed++
+					}
+				}
+				if opts.Progress != nil {
+					opts.Progress(progress)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	interrupted := false
+feed:
+	for _, chunk := range chunks {
+		select {
+		case <-opts.Stop:
+			interrupted = true
+			break feed
+		case <-aborted:
+			break feed
+		default:
+		}
+		select {
+		case <-opts.Stop:
+			interrupted = true
+			break feed
+		case <-aborted:
+			break feed
+		case jobs <- chunk:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if saveErr != nil {
+		return saveErr
+	}
+	if interrupted {
+		return ErrBulkInterrupted
+	}
+	return nil
+}
+
+// CheckRemainingAdmins returns error if no enabled admin remains among `admins` after `usernames` are removed.
+func CheckRemainingAdmins(admins []User, usernames []string) error {
+	removing := make(map[string]bool)
+	for _, name := range usernames {
+		removing[strings.ToLower(name)] = true
+	}
+	remaining := 0
+	removed := []string{}
+	for _, a := range admins {
+		if a.Disabled {
+			continue
+		}
+		if removing[strings.ToLower(a.UserName)] {
+			removed = append(removed, a.UserName)
+			continue
+		}
+		remaining++
+	}
+	if len(removed) != 0 && remaining == 0 {
+		return fmt.Errorf("No admin would remain after removing %v", strings.Join(removed, ", "))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package lpmgt
 
 import (
+	"io"
 	"os"
 	"errors"
 	"net/http"
@@ -19,6 +20,30 @@ type LastPassClient struct {
 	Logger    *log.Logger
 	Headers   http.Header
 	CompanyID string
+	// ReadOnly rejects commands which mutate the tenant.
+	ReadOnly bool
+	// DryRun prints commands which mutate the tenant to DryRunWriter instead of sending them.
+	DryRun       bool
+	DryRunWriter io.Writer
+	// Journal records commands which mutate the tenant if it's set.
+	Journal *Journal
+	// Proposal collects commands which mutate the tenant instead of sending them if it's set.
+	Proposal *Proposal
+}
+
+// RedactedProvisioningHash replaces provisioning hash in requests shown to users.
+const RedactedProvisioningHash = "********"
+
+// readOnlyCommands are commands of LastPass Provisioning API which do not mutate the tenant.
+var readOnlyCommands = map[string]bool{
+	"getuserdata": true,
+	"getsfdata":   true,
+	"reporting":   true,
+}
+
+// IsReadOnlyCommand reports whether `command` does not mutate the tenant.
+func IsReadOnlyCommand(command string) bool {
+	return readOnlyCommands[command]
 }
 
 func init() {
@@ -61,11 +86,50 @@ func NewLastPassClient(configFilePath string) (*LastPassClient, error) {
 		endPointURL = defaultBaseURL
 	}
 
-	return NewClient(apiKey, endPointURL, companyID, os.Getenv("DEBUG") != "")
+	client, err := NewClient(apiKey, endPointURL, companyID, os.Getenv("DEBUG") != "")
+	if err != nil {
+		return nil, err
+	}
+	client.ReadOnly, err = LoadReadOnly(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
 }
 
 // DoRequest executes LastPass specific request in JSON format and returns http Response
 func (c *LastPassClient) DoRequest(command string, payload interface{}) (*http.Response, error) {
+	if c.ReadOnly && !IsReadOnlyCommand(command) {
+		return nil, fmt.Errorf("Command %v is rejected in read-only mode. Only getuserdata, getsfdata and reporting are allowed", command)
+	}
+
+	if c.Proposal != nil && !c.DryRun && !IsReadOnlyCommand(command) {
+		prior, err := c.snapshot(payload)
+		if err != nil {
+			return nil, fmt.Errorf("Failed taking snapshot for proposal: %v", err)
+		}
+		if err := c.Proposal.add(command, payload, prior); err != nil {
+			return nil, err
+		}
+		return acceptedResponse(), nil
+	}
+
+	if c.Journal == nil || c.DryRun || IsReadOnlyCommand(command) {
+		return c.doRequest(command, payload)
+	}
+
+	prior, err := c.snapshot(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Failed taking snapshot for journal: %v", err)
+	}
+	resp, err := c.doRequest(command, payload)
+	if jerr := c.Journal.record(command, payload, prior, resp, err); jerr != nil {
+		Log("warning", fmt.Sprintf("Failed recording %v in journal: %v", command, jerr))
+	}
+	return resp, err
+}
+
+func (c *LastPassClient) doRequest(command string, payload interface{}) (*http.Response, error) {
 	data := struct {
 		CompanyID        string      `json:"cid"`
 		ProvisioningHash string      `json:"provhash"`
@@ -81,6 +145,11 @@ func (c *LastPassClient) DoRequest(command string, payload interface{}) (*http.R
 		data.Payload = payload
 	}
 
+	if c.DryRun && !IsReadOnlyCommand(command) {
+		data.ProvisioningHash = RedactedProvisioningHash
+		return c.dryRun(data)
+	}
+
 	// Form body.
 	body, err := JSONReader(data)
 	if err != nil {
@@ -140,4 +209,19 @@ func (c *LastPassClient) DoRequest(command string, payload interface{}) (*http.R
 	}
 
 	return resp, err
-}
\ No newline at end of file
+}
+
+// dryRun prints the request and returns a response as if LastPass accepted it.
+func (c *LastPassClient) dryRun(data interface{}) (*http.Response, error) {
+	b, err := IndentedJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	w := c.DryRunWriter
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintf(w, "# dry-run: the following request is not sent\n%s\n", b)
+
+	return acceptedResponse(), nil
+}
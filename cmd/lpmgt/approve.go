@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	lp "lpmgt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// proposal collects mutating requests when --propose is given.
+var proposal *lp.Proposal
+
+// saveProposal signs collected requests by the operator key and writes them to `file`.
+// No mutating request has been sent.
+func saveProposal(context *cli.Context, file string) error {
+	if proposal == nil || len(proposal.Calls) == 0 {
+		lp.Log("warning", "Nothing to propose")
+		return nil
+	}
+	config := LoadConfigFromContext(context)
+	key := loadOperatorKey(config)
+	requester, err := lp.IdentifyOperator(config.Operators, key)
+	lp.DieIf(errors.Wrap(err, "Failed signing change request"))
+	lp.DieIf(errors.Wrap(proposal.Sign(requester, key), "Failed signing change request"))
+	lp.DieIf(errors.Wrapf(proposal.Save(file), "Failed writing change request to %v", file))
+	lp.Log("proposed", fmt.Sprintf("%d request(s) in %v. Run `lpmgt approve %v` as another operator", len(proposal.Calls), file, file))
+	return nil
+}
+
+// loadOperatorKey loads the private key at operator_key_file in config or ~/.lpmgt/operator.key.
+func loadOperatorKey(config *lp.LastPassConfig) ed25519.PrivateKey {
+	path := config.OperatorKeyFile
+	if path == "" {
+		path = lp.DefaultOperatorKeyFile()
+	}
+	key, err := lp.LoadOperatorKey(path)
+	lp.DieIf(errors.Wrapf(err, "Failed loading operator key %v. Run `lpmgt keygen` and register the public key in config", path))
+	return key
+}
+
+var commandKeygen = cli.Command{
+	Name:  "keygen",
+	Usage: "generate a key of this operator to sign and approve change requests",
+	Description: `
+   Write a new private key to operator_key_file in config or ~/.lpmgt/operator.key, and print
+   the public key to be registered under 'operators:' in config shared by operators.
+`,
+	Action: doKeygen,
+}
+
+func doKeygen(context *cli.Context) error {
+	path := LoadConfigFromContext(context).OperatorKeyFile
+	if path == "" {
+		path = lp.DefaultOperatorKeyFile()
+	}
+	public, err := lp.GenerateOperatorKey(path)
+	lp.DieIf(errors.Wrapf(err, "Failed generating operator key %v", path))
+	lp.Log("generated", fmt.Sprintf("Private key is written to %v. Register the public key below in config", path))
+	fmt.Printf("operators:\n  %v: %v\n", lp.OperatorName(), public)
+	return nil
+}
+
+var commandApprove = cli.Command{
+	Name:      "approve",
+	Usage:     "approve and execute a change request proposed by another operator",
+	ArgsUsage: "[--override-protection] [--force] <file>",
+	Description: `
+   Execute requests in a change request written by '--propose <file>'.
+   The change request has to be signed by the requester registered under 'operators:' in config,
+   the approver has to hold the key of a different operator, and the users must be in the same
+   state as when the change was proposed. Protected users and groups, the last admin and
+   separation-of-duties rules are checked again before sending.
+`,
+	Before: updateLocation,
+	Action: doApprove,
+	Flags: []cli.Flag{
+		overrideProtectionFlag,
+		cli.BoolFlag{Name: "force", Usage: "Approve even if separation-of-duties violations would be created"},
+		cli.StringFlag{Name: "rules", Usage: "Load separation-of-duties rules from <file> instead of sod_rules_file in config"},
+	},
+}
+
+func doApprove(context *cli.Context) error {
+	file := context.Args().Get(0)
+	if file == "" {
+		lp.DieIf(errors.New("Change request file has to be specified"))
+	}
+	if context.GlobalString("propose") != "" {
+		lp.DieIf(errors.New("A change request cannot be approved with --propose"))
+	}
+	p, err := lp.LoadProposal(file)
+	lp.DieIf(errors.Wrapf(err, "Failed reading change request %v", file))
+
+	fmt.Printf("# Change request %v by %v at %v, expiring at %v\n", p.ID, p.Requester,
+		p.RequestedAt.In(location).Format(time.RFC3339), p.ExpiresAt.In(location).Format(time.RFC3339))
+	fmt.Printf("$ %v\n", p.CommandLine)
+	for _, call := range p.Calls {
+		fmt.Printf("- %v %s\n", call.Command, call.Payload)
+	}
+
+	config := LoadConfigFromContext(context)
+	c := NewLastPassClientFromContext(context)
+	approver, err := lp.VerifyProposal(c, p, config.Operators, loadOperatorKey(config))
+	lp.DieIf(err)
+
+	s := lp.NewUserService(c)
+	admins, err := s.GetAdminUserData()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAdminUserData", s))
+	reasons, violations, err := p.Guard(lp.NewProtection(config), loadSoDRules(context), admins)
+	lp.DieIf(err)
+	refuseProtection(context, reasons)
+	refuseSoDViolations(context, violations)
+
+	results, err := lp.ExecuteProposal(c, p, approver)
+	for _, r := range results {
+		if r.Error != "" {
+			lp.Log("error", fmt.Sprintf("%v: %v", r.Command, r.Error))
+			continue
+		}
+		lp.Log("approved", fmt.Sprintf("%v: %v by %v", r.Command, r.Status.String(), approver))
+	}
+	lp.DieIf(err)
+	return nil
+}
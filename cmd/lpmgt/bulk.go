@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	lp "lpmgt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// bulkFlags are shared by commands which act on many users.
+var bulkFlags = []cli.Flag{
+	cli.StringFlag{Name: "checkpoint", Usage: "Save progress to checkpoint <file> (Default: lpmgt-<operation>-<timestamp>.json)"},
+	cli.StringFlag{Name: "resume", Usage: "Resume an interrupted run from checkpoint <file>"},
+	cli.BoolFlag{Name: "retry-failed", Usage: "Rerun only failed users in the checkpoint given by --resume"},
+	cli.IntFlag{Name: "workers", Value: lp.DefaultBulkWorkers, Usage: "Number of requests sent concurrently"},
+}
+
+var bulkFileFlag = cli.StringFlag{Name: "bulk, b, file, f", Usage: "Act on users in a <file>: a list of emails or the same formats as 'create user --bulk'"}
+
+var bulkFormatFlag = cli.StringFlag{Name: "format", Usage: "Format of bulk file: txt, json, jsonl, yaml or csv (Default: auto-detect)"}
+
+// bulkSelectFlags select users in the tenant instead of listing them in a file.
+var bulkSelectFlags = []cli.Flag{
+	cli.StringSliceFlag{Name: "group, g", Value: &cli.StringSlice{}, Usage: "Act on members of <group>"},
+	cli.StringSliceFlag{Name: "where", Value: &cli.StringSlice{}, Usage: "Act on users matching <filter> such as 'multifactor=duo,attr.dept=Sales'. Narrows --group if both are given"},
+}
+
+// isBulk reports whether the command acts on users in a file, selected users or users in a checkpoint.
+func isBulk(context *cli.Context) bool {
+	return context.String("bulk") != "" || context.String("resume") != "" ||
+		len(context.StringSlice("group")) != 0 || len(context.StringSlice("where")) != 0
+}
+
+// openCheckpoint loads the checkpoint given by --resume, or creates one over items returned by `newItems`.
+func openCheckpoint(context *cli.Context, operation string, newItems func() ([]lp.BulkItem, map[string]string)) *lp.Checkpoint {
+	if context.Bool("retry-failed") && context.String("resume") == "" {
+		lp.DieIf(errors.New("--retry-failed needs a checkpoint given by --resume"))
+	}
+
+	if file := context.String("resume"); file != "" {
+		cp, err := lp.LoadCheckpoint(file)
+		lp.DieIf(errors.Wrapf(err, "Failed loading checkpoint %v", file))
+		if cp.Operation != operation {
+			lp.DieIf(errors.Errorf("Checkpoint %v is of %v, not %v", file, cp.Operation, operation))
+		}
+		lp.Log("resume", fmt.Sprintf("%v: %d user(s) to go", file, len(cp.Pending(context.Bool("retry-failed")))))
+		return cp
+	}
+
+	items, options := newItems()
+	path := context.String("checkpoint")
+	if isDryRun(context) {
+		path = ""
+	} else if path == "" {
+		path = fmt.Sprintf("lpmgt-%v-%v.json", operation, time.Now().UTC().Format("20060102T150405Z"))
+	}
+	cp := lp.NewCheckpoint(path, operation, options, items)
+	lp.DieIf(errors.Wrapf(cp.Save(), "Failed saving checkpoint %v", path))
+	if path != "" {
+		lp.Log("checkpoint", path)
+	}
+	return cp
+}
+
+// loadBulkItems reads usernames from the file given by --bulk.
+func loadBulkItems(context *cli.Context) []lp.BulkItem {
+	users := loadUsersFile(context)
+	items := []lp.BulkItem{}
+	for _, u := range users {
+		items = append(items, lp.BulkItem{UserName: u.UserName})
+	}
+	return items
+}
+
+// selectBulkItems returns members of groups given by --group, narrowed by --where.
+// Users are selected from all users when only --where is given.
+func selectBulkItems(context *cli.Context, s *lp.UserService) []lp.BulkItem {
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+
+	if groups := context.StringSlice("group"); len(groups) != 0 {
+		members := lp.GroupMembers(users)
+		seen := make(map[string]bool)
+		selected := []lp.User{}
+		for _, g := range groups {
+			if _, ok := members[g]; !ok {
+				lp.DieIf(errors.Errorf("Group %v does not exist", g))
+			}
+			for _, u := range members[g] {
+				if !seen[strings.ToLower(u.UserName)] {
+					seen[strings.ToLower(u.UserName)] = true
+					selected = append(selected, u)
+				}
+			}
+		}
+		users = selected
+	}
+	if where := context.StringSlice("where"); len(where) != 0 {
+		filter, err := lp.ParseUserFilter(where...)
+		lp.DieIf(err)
+		users = filter.Select(users)
+	}
+
+	items := []lp.BulkItem{}
+	for _, u := range users {
+		items = append(items, lp.BulkItem{UserName: u.UserName})
+	}
+	if len(items) == 0 {
+		lp.DieIf(errors.New("No user is selected"))
+	}
+	return items
+}
+
+// loadUsersFile reads users from the file given by --bulk and exits on errors.
+func loadUsersFile(context *cli.Context) []lp.User {
+	users, err := lp.LoadUsersFile(context.String("bulk"), lp.UsersFileFormat(context.String("format")))
+	if errs, ok := err.(lp.UsersFileErrors); ok {
+		for _, e := range errs {
+			lp.Log("error", e.Error())
+		}
+		lp.DieIf(errors.Errorf("%d error(s) found in %v", len(errs), context.String("bulk")))
+	}
+	lp.DieIf(err)
+	return users
+}
+
+// checkBulkTargets records users who do not exist as skipped, and protected users as failed
+// unless --override-protection is given. Records of the rest of pending users are returned.
+func checkBulkTargets(context *cli.Context, s *lp.UserService, cp *lp.Checkpoint) []lp.User {
+	allUsers, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+	existing := make(map[string]lp.User)
+	for _, u := range allUsers {
+		existing[strings.ToLower(u.UserName)] = u
+	}
+	protection := lp.NewProtection(LoadConfigFromContext(context))
+
+	targets := []lp.User{}
+	indexes := []int{}
+	results := []lp.UserResult{}
+	for _, i := range cp.Pending(context.Bool("retry-failed")) {
+		name := cp.Items[i].UserName
+		u, ok := existing[strings.ToLower(name)]
+		if !ok {
+			indexes = append(indexes, i)
+			results = append(results, lp.UserResult{UserName: name, Result: lp.Skipped, Reason: "does not exist"})
+			continue
+		}
+		if reason := protection.Reason(u); reason != "" {
+			if !context.Bool("override-protection") {
+				indexes = append(indexes, i)
+				results = append(results, lp.UserResult{UserName: name, Result: lp.Failed, Reason: reason})
+				continue
+			}
+			lp.Log("warning", reason)
+		}
+		targets = append(targets, u)
+	}
+	lp.DieIf(errors.Wrapf(cp.Record(indexes, results), "Failed saving checkpoint %v", cp.Path()))
+	return targets
+}
+
+// doInBulk runs `do` for each user in the file given by --bulk or selected by --group and --where,
+// skipping users who do not exist and refusing protected users.
+func doInBulk(context *cli.Context, operation string, do func(s *lp.UserService, item lp.BulkItem) error) error {
+	c := NewLastPassClientFromContext(context)
+	cp := openCheckpoint(context, operation, func() ([]lp.BulkItem, map[string]string) {
+		if context.String("bulk") != "" {
+			if len(context.StringSlice("group")) != 0 || len(context.StringSlice("where")) != 0 {
+				lp.DieIf(errors.New("--file cannot be combined with --group or --where"))
+			}
+			return loadBulkItems(context), map[string]string{"file": context.String("bulk")}
+		}
+		options := map[string]string{
+			"group": strings.Join(context.StringSlice("group"), ","),
+			"where": strings.Join(context.StringSlice("where"), ","),
+		}
+		return selectBulkItems(context, lp.NewUserService(c)), options
+	})
+	checkBulkTargets(context, lp.NewUserService(c), cp)
+	return runBulk(context, cp, 1, eachBulkItem(c, do))
+}
+
+// eachBulkItem converts `do` acting on a single user into a function handling a chunk for runBulk.
+// Each call has its own UserService because UserService is not safe for concurrent use.
+func eachBulkItem(c *lp.LastPassClient, do func(s *lp.UserService, item lp.BulkItem) error) func(items []lp.BulkItem) []lp.UserResult {
+	return func(items []lp.BulkItem) []lp.UserResult {
+		s := lp.NewUserService(c)
+		results := []lp.UserResult{}
+		for _, item := range items {
+			r := lp.UserResult{UserName: item.UserName, Result: lp.Done}
+			if err := do(s, item); err != nil {
+				r.Result = lp.Failed
+				r.Reason = err.Error()
+			}
+			results = append(results, r)
+		}
+		return results
+	}
+}
+
+// runBulk runs `do` over pending users of the checkpoint, showing progress on stderr.
+// Ctrl-C stops handing out users, and the run can be resumed by --resume.
+func runBulk(context *cli.Context, cp *lp.Checkpoint, chunkSize int, do func(items []lp.BulkItem) []lp.UserResult) error {
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			lp.Log("warning", "Interrupted. Waiting for requests in flight")
+			close(stop)
+			// A second Ctrl-C kills lpmgt immediately.
+			signal.Stop(interrupt)
+		}
+	}()
+	defer func() {
+		signal.Stop(interrupt)
+		close(interrupt)
+	}()
+
+	opts := lp.BulkOptions{
+		Workers:     context.Int("workers"),
+		ChunkSize:   chunkSize,
+		RetryFailed: context.Bool("retry-failed"),
+		Stop:        stop,
+		Progress: func(p lp.BulkProgress) {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %d failed\n", p.Done, p.Total, p.Failed)
+		},
+	}
+	err := lp.RunBulk(cp, opts, do)
+
+	results := cp.Results()
+	printUserResults(results)
+	counts := make(map[lp.BatchResult]int)
+	for _, r := range results {
+		counts[r.Result]++
+	}
+	summary := []string{}
+	for _, result := range []lp.BatchResult{lp.Created, lp.Updated, lp.Done, lp.Skipped, lp.Failed, "pending"} {
+		if counts[result] != 0 {
+			summary = append(summary, fmt.Sprintf("%d %v", counts[result], result))
+		}
+	}
+	lp.Log("summary", strings.Join(summary, ", "))
+	failed := counts[lp.Failed]
+	if err == lp.ErrBulkInterrupted && cp.Path() != "" {
+		lp.DieIf(errors.Errorf("%v. Continue with --resume %v", err, cp.Path()))
+	}
+	lp.DieIf(err)
+	if failed != 0 {
+		message := fmt.Sprintf("%d of %d user(s) failed", failed, len(results))
+		if cp.Path() != "" {
+			message += fmt.Sprintf(". Rerun them with --resume %v --retry-failed", cp.Path())
+		}
+		lp.DieIf(errors.New(message))
+	}
+	return nil
+}
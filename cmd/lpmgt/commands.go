@@ -4,11 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"io"
 	"github.com/urfave/cli"
-	"io/ioutil"
 	lp "lpmgt"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 	"os"
 )
@@ -37,9 +38,63 @@ func NewLastPassClientFromContext(context *cli.Context) *lp.LastPassClient {
 	confFile := context.GlobalString("config")
 	client, err := lp.NewLastPassClient(confFile)
 	lp.DieIf(err)
+	if context.GlobalBool("read-only") {
+		client.ReadOnly = true
+	}
+	client.DryRun = context.GlobalBool("dry-run")
+	client.Journal = NewJournalFromContext(context)
+	if context.GlobalString("propose") != "" {
+		if proposal == nil {
+			var ttl time.Duration
+			if v := LoadConfigFromContext(context).ProposalTTL; v != "" {
+				ttl, err = time.ParseDuration(v)
+				if err != nil || ttl <= 0 {
+					lp.DieIf(errors.Errorf("proposal_ttl has to be a positive duration such as 24h: %v", v))
+				}
+			}
+			proposal = lp.NewProposal(context.GlobalString("config"), strings.Join(os.Args, " "), client.CompanyID, ttl)
+		}
+		client.Proposal = proposal
+	}
 	return client
 }
 
+// isDryRun reports whether either global or command's --dry-run is given.
+func isDryRun(context *cli.Context) bool {
+	return context.GlobalBool("dry-run") || context.Bool("dry-run")
+}
+
+// logSent logs the result of a mutating request. Under dry-run, the request was not sent
+// and the result is labelled so as not to be taken as done.
+func logSent(context *cli.Context, prefix, message string) {
+	if isDryRun(context) {
+		lp.Log("dry-run", fmt.Sprintf("%v %v (not sent)", prefix, message))
+		return
+	}
+	lp.Log(prefix, message)
+}
+
+// printDryRunTarget outputs current state of the user which a mutating command would act on.
+func printDryRunTarget(context *cli.Context, user lp.User) {
+	if !isDryRun(context) {
+		return
+	}
+	fmt.Println("# dry-run: current state of the user")
+	lp.PrintIndentedJSON(user)
+}
+
+// LoadConfigFromContext loads LastPassConfig from the file given by --config.
+// Default config is returned when no file is specified.
+func LoadConfigFromContext(context *cli.Context) *lp.LastPassConfig {
+	confFile := context.GlobalString("config")
+	if confFile == "" {
+		return &lp.LastPassConfig{}
+	}
+	config, err := lp.LoadConfig(confFile)
+	lp.DieIf(errors.Wrapf(err, "Failed loading config %v", confFile))
+	return config
+}
+
 // Commands cli.Command object list
 var Commands = []cli.Command{
 	commandCreate,
@@ -49,6 +104,14 @@ var Commands = []cli.Command{
 	commandUpdate,
 	subCommandDisableMFA,
 	subCommandResetPassword,
+	commandOffboard,
+	commandOnboard,
+	commandGroup,
+	commandGrant,
+	commandHistory,
+	commandUndo,
+	commandApprove,
+	commandKeygen,
 }
 
 // Update command with subcommands
@@ -62,12 +125,20 @@ var commandUpdate = cli.Command{
 
 var subCommandDisableMFA = cli.Command{
 	Name:      "disable-mfa",
-	Usage:     "disable mfa of user <email>",
-	ArgsUsage: "<email>",
+	Usage:     "disable mfa of user <email>, or users in a file or selected by group and filter",
+	ArgsUsage: "[--override-protection] <email> | --file | -f <file> [--format <format>] | [[--group | -g <group>]...] [[--where <filter>]...] [--checkpoint <file>] [--workers <n>] | --resume <file> [--retry-failed]",
 	Action:    doDisableMFA,
+	Flags:     append(append([]cli.Flag{bulkFileFlag, bulkFormatFlag, overrideProtectionFlag}, bulkSelectFlags...), bulkFlags...),
 }
 
 func doDisableMFA(context *cli.Context) error {
+	if isBulk(context) {
+		return doInBulk(context, "disable-mfa", func(s *lp.UserService, item lp.BulkItem) error {
+			_, err := s.DisableMultifactor(item.UserName)
+			return err
+		})
+	}
+
 	argUserName := context.Args().Get(0)
 	if argUserName == "" {
 		lp.DieIf(errors.New("Email(username) has to be specified"))
@@ -75,21 +146,33 @@ func doDisableMFA(context *cli.Context) error {
 
 	c := NewLastPassClientFromContext(context)
 	s := lp.NewUserService(c)
+	user, err := s.GetUserData(argUserName)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetUserData", s))
+	refuseProtectedUser(context, user)
+	printDryRunTarget(context, user)
 
 	status, err := s.DisableMultifactor(argUserName)
 	lp.DieIf(errors.Wrapf(err, "Failed executing %T.DisableMultifactor", s))
-	lp.Log(context.Command.Name, status.String())
+	logSent(context, context.Command.Name, fmt.Sprintf("%v: %v", argUserName, status.String()))
 	return nil
 }
 
 var subCommandResetPassword = cli.Command{
 	Name:      "reset-password",
-	Usage:     "reset password of user <email>",
-	ArgsUsage: "<email>",
+	Usage:     "reset password of user <email>, or users in a file or selected by group and filter",
+	ArgsUsage: "[--override-protection] <email> | --file | -f <file> [--format <format>] | [[--group | -g <group>]...] [[--where <filter>]...] [--checkpoint <file>] [--workers <n>] | --resume <file> [--retry-failed]",
 	Action:    doResetPassword,
+	Flags:     append(append([]cli.Flag{bulkFileFlag, bulkFormatFlag, overrideProtectionFlag}, bulkSelectFlags...), bulkFlags...),
 }
 
 func doResetPassword(context *cli.Context) error {
+	if isBulk(context) {
+		return doInBulk(context, "reset-password", func(s *lp.UserService, item lp.BulkItem) error {
+			_, err := s.ResetPassword(item.UserName)
+			return err
+		})
+	}
+
 	argUserName := context.Args().Get(0)
 	if argUserName == "" {
 		lp.DieIf(errors.New("Email(username) has to be specified"))
@@ -97,10 +180,14 @@ func doResetPassword(context *cli.Context) error {
 
 	c := NewLastPassClientFromContext(context)
 	s := lp.NewUserService(c)
+	user, err := s.GetUserData(argUserName)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetUserData", s))
+	refuseProtectedUser(context, user)
+	printDryRunTarget(context, user)
 
 	status, err := s.ResetPassword(argUserName)
 	lp.DieIf(errors.Wrapf(err, "Failed executing %T.ResetPassword", s))
-	lp.Log(context.Command.Name, status.String())
+	logSent(context, context.Command.Name, fmt.Sprintf("%v: %v", argUserName, status.String()))
 	return nil
 }
 
@@ -108,10 +195,16 @@ var subCommandUpdateUser = cli.Command{
 	Name:        "user",
 	Usage:       "update user <email>",
 	Description: `update a <email>`,
-	ArgsUsage:   "[[--leave | -l <department>]...] [[--join | -j <department>]...]",
+	ArgsUsage:   "[[--leave | -l <department>]...] [[--join | -j <department>]...] [--fullname <name>] [[--attr <key=value>]...] [--duo-username <name>] [--require-password-change] <email>",
 	Flags: []cli.Flag{
 		cli.StringSliceFlag{Name: "leave, l", Value: &cli.StringSlice{}, Usage: "leave current department"},
 		cli.StringSliceFlag{Name: "join, j", Value: &cli.StringSlice{}, Usage: "join new department"},
+		cli.StringFlag{Name: "fullname", Usage: "set full name"},
+		cli.StringSliceFlag{Name: "attr", Value: &cli.StringSlice{}, Usage: "set custom attribute in <key=value>. Empty value removes the attribute"},
+		cli.StringFlag{Name: "duo-username", Usage: "set Duo Security username"},
+		cli.BoolFlag{Name: "require-password-change", Usage: "require the user to change the master password"},
+		cli.BoolFlag{Name: "force", Usage: "proceed even if separation-of-duties rules are violated"},
+		overrideProtectionFlag,
 	},
 	Action: doUpdateBelongingDepartment,
 }
@@ -128,12 +221,23 @@ func doUpdateBelongingDepartment(context *cli.Context) error {
 	// Fetch User if he/she exists
 	user, err := s.GetUserData(argUserName)
 	lp.DieIf(errors.Wrapf(err, "Failed executing %T.doUpdateBelongingDepartment", s))
+	before := user
+	before.Groups = append([]string{}, user.Groups...)
 
 	// Join
 	user.Groups = append(user.Groups, context.StringSlice("join")...)
 
 	// Leave
 	leave := context.StringSlice("leave")
+	leaving := []string{}
+	for _, dep := range leave {
+		for _, g := range user.Groups {
+			if g == dep {
+				leaving = append(leaving, dep)
+			}
+		}
+	}
+	refuseLeavingProtectedGroups(context, user.UserName, leaving)
 	for i := 0; i < len(leave); i++ {
 		newDeps := []string{}
 		for _, dep := range user.Groups {
@@ -144,10 +248,52 @@ func doUpdateBelongingDepartment(context *cli.Context) error {
 		user.Groups = newDeps
 	}
 
+	// Attributes
+	if context.IsSet("fullname") {
+		user.FullName = context.String("fullname")
+	}
+	if context.IsSet("duo-username") {
+		user.Duousername = context.String("duo-username")
+	}
+	if context.Bool("require-password-change") {
+		user.PasswordResetRequired = true
+	}
+	attrs, err := parseAttributes(context.StringSlice("attr"))
+	lp.DieIf(err)
+	if len(attrs) != 0 {
+		newAttrs := make(map[string]string)
+		for k, v := range before.Attributes {
+			newAttrs[k] = v
+		}
+		for k, v := range attrs {
+			if v == "" {
+				delete(newAttrs, k)
+				continue
+			}
+			newAttrs[k] = v
+		}
+		user.Attributes = newAttrs
+	}
+
+	if rules := loadSoDRules(context); rules != nil {
+		refuseSoDViolations(context, rules.NewViolations(before, user))
+	}
+
+	// Show what is going to be changed
+	changes, err := lp.DiffUsers(before, user)
+	lp.DieIf(err)
+	if len(changes) == 0 {
+		lp.Log("skipped", fmt.Sprintf("%v: nothing to update", user.UserName))
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Println(c.String())
+	}
+
 	// Update
 	err = s.UpdateUser(user)
 	lp.DieIf(errors.Wrapf(err, "Failed executing %T.UpdateUser", s))
-	lp.Log("updated", user.UserName)
+	logSent(context, "updated", user.UserName)
 	return nil
 }
 
@@ -163,54 +309,154 @@ var commandDelete = cli.Command{
 var subCommandDeleteUser = cli.Command{
 	Name:        "user",
 	Usage:       "delete user <email>",
-	Description: `delete a <email> by choosing either 'deactivate(default)' or 'delete'`,
-	ArgsUsage:   "[--mode | -m <deleteMode>] <email>",
+	Description: `delete a <email> by choosing either 'deactivate(default)', 'remove' or 'delete'`,
+	ArgsUsage:   "[--mode | -m <deleteMode>] [--override-protection] <email> | --bulk | -b <file> [--format <format>] [--checkpoint <file>] [--workers <n>] | --resume <file> [--retry-failed]",
 	Action:      doDeleteUser,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{Name: "mode, m", Value: "deactivate", Usage: "deleteMode"},
-	},
+		bulkFileFlag,
+		bulkFormatFlag,
+		overrideProtectionFlag,
+	}, bulkFlags...),
 }
 
 func doDeleteUser(context *cli.Context) error {
+	if isBulk(context) {
+		return doDeleteUsersInBulk(context)
+	}
+
 	argUserName := context.Args().Get(0)
 	if argUserName == "" {
 		lp.DieIf(errors.New("Email(username) has to be specified"))
 	}
 
-	var mode = lp.DeactivationMode(lp.Deactivate)
-	switch context.String("mode") {
-	case "deactivate":
-		mode = lp.Deactivate
-	case "delete":
-		mode = lp.Delete
-	default:
-		mode = lp.Deactivate
-	}
+	mode, err := parseDeactivationMode(context.String("mode"))
+	lp.DieIf(err)
 
 	s := lp.NewUserService(NewLastPassClientFromContext(context))
-	err := s.DeleteUser(argUserName, mode)
+	user, err := s.GetUserData(argUserName)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetUserData", s))
+	refuseProtectedUser(context, user)
+	refuseLastAdmin(s, user.UserName)
+	printDryRunTarget(context, user)
+
+	err = s.DeleteUser(argUserName, mode)
 	lp.DieIf(errors.Wrapf(err, "Failed executing %T.DeleteUser", s))
-	lp.Log(context.String("mode"), argUserName)
+	logSent(context, context.String("mode"), argUserName)
 	return nil
 }
 
+func doDeleteUsersInBulk(context *cli.Context) error {
+	cp := openCheckpoint(context, "delete-users", func() ([]lp.BulkItem, map[string]string) {
+		mode := context.String("mode")
+		_, err := parseDeactivationMode(mode)
+		lp.DieIf(err)
+		return loadBulkItems(context), map[string]string{"mode": mode}
+	})
+	mode, err := parseDeactivationMode(cp.Options["mode"])
+	lp.DieIf(err)
+
+	c := NewLastPassClientFromContext(context)
+	s := lp.NewUserService(c)
+	targets := checkBulkTargets(context, s, cp)
+	names := []string{}
+	for _, u := range targets {
+		names = append(names, u.UserName)
+	}
+	admins, err := s.GetAdminUserData()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAdminUserData", s))
+	lp.DieIf(lp.CheckRemainingAdmins(admins, names))
+
+	return runBulk(context, cp, 1, eachBulkItem(c, func(s *lp.UserService, item lp.BulkItem) error {
+		return s.DeleteUser(item.UserName, mode)
+	}))
+}
+
+// parseDeactivationMode converts either 'deactivate', 'remove' or 'delete' into lp.DeactivationMode.
+func parseDeactivationMode(mode string) (lp.DeactivationMode, error) {
+	switch mode {
+	case "", "deactivate":
+		return lp.Deactivate, nil
+	case "remove":
+		return lp.Remove, nil
+	case "delete":
+		return lp.Delete, nil
+	default:
+		return lp.Deactivate, errors.Errorf("Unknown mode %v: choose from 'deactivate', 'remove' or 'delete'", mode)
+	}
+}
+
 // Describe command with subcommands
 var commandDescribe = cli.Command{
 	Name:  "describe",
 	Usage: "describe specific object",
 	Subcommands: []cli.Command{
 		subCommandDescribeUser,
-		// TODO
-		// subCommandDescribeGroup,
+		subCommandDescribeGroup,
 	},
 }
 
+var subCommandDescribeGroup = cli.Command{
+	Name:        "group",
+	Usage:       "describe group",
+	Description: `Show members of the group with <name> with their status, last login and shared folders`,
+	ArgsUsage:   "[--json] <name>",
+	Before:      updateLocation,
+	Action:      doDescribeGroup,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "json", Usage: "Output in JSON format"},
+	},
+}
+
+func doDescribeGroup(context *cli.Context) error {
+	argGroupName := context.Args().Get(0)
+	if argGroupName == "" {
+		lp.DieIf(errors.New("Group name has to be specified"))
+	}
+
+	c := NewLastPassClientFromContext(context)
+	s := lp.NewUserService(c)
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+
+	fs := lp.NewFolderService(c)
+	folders, err := fs.GetSharedFolders()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetSharedFolders", fs))
+
+	group, err := lp.DescribeGroup(argGroupName, users, folders)
+	lp.DieIf(err)
+	if context.Bool("json") {
+		return lp.PrintIndentedJSON(group)
+	}
+
+	fmt.Printf("# %v\n", group.Name)
+	fmt.Printf("- Members: %d\n- MFA: %d (%.1f%%)\n- Disabled: %d\n\n",
+		group.NumberOfMembers, group.MFAEnabled, group.MFAPercentage, group.Disabled)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tSTATUS\tADMIN\tMFA\tLAST LOGIN\tSHARED FOLDERS")
+	for _, m := range group.Members {
+		lastLogin := m.LastLogin
+		if t, err := lp.ParseLastPassTime(m.LastLogin); err == nil {
+			lastLogin = t.In(location).Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			m.UserName, m.Status, m.IsAdmin, m.Multifactor, lastLogin, strings.Join(m.SharedFolders, ", "))
+	}
+	return w.Flush()
+}
+
 var subCommandDescribeUser = cli.Command{
 	Name:        "user",
 	Usage:       "describe user",
-	Description: `Show the information of the user with <email>`,
-	ArgsUsage:   "<email>",
+	Description: `Show the information of the user with <email> together with shared folders, recent events and flags to be reviewed`,
+	ArgsUsage:   "[--days | -d <days>] [--stale-days <days>] [--json] <email>",
+	Before:      updateLocation,
 	Action:      doDescribeUser,
+	Flags: []cli.Flag{
+		cli.IntFlag{Name: "days, d", Value: 7, Usage: "Show events from <days> ago"},
+		cli.IntFlag{Name: "stale-days", Value: 90, Usage: "Flag the user if the last login is older than <days>"},
+		cli.BoolFlag{Name: "json", Usage: "Output in JSON format"},
+	},
 }
 
 func doDescribeUser(context *cli.Context) error {
@@ -219,11 +465,68 @@ func doDescribeUser(context *cli.Context) error {
 		lp.DieIf(errors.New("Email(username) has to be specified"))
 	}
 
-	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	c := NewLastPassClientFromContext(context)
+	s := lp.NewUserService(c)
 	user, err := s.GetUserData(argUserName)
 	lp.DieIf(errors.Wrapf(err, "Failed executing %T.doDescribeUser(%v)", s, argUserName))
 
-	lp.PrintIndentedJSON(user)
+	fs := lp.NewFolderService(c)
+	folders, err := fs.GetSharedFolders()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetSharedFolders", fs))
+
+	loc, _ := time.LoadLocation(lp.LastPassTimeZone)
+	now := time.Now().In(loc)
+	from := lp.JSONLastPassTime{JSONTime: now.AddDate(0, 0, -context.Int("days"))}
+	to := lp.JSONLastPassTime{JSONTime: now}
+	es := lp.NewEventService(c)
+	events, err := es.GetEventReport(user.UserName, "", from, to)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetEventReport", es))
+	events.ConvertTimezone(location)
+
+	profile := lp.NewUserProfile(user, folders, events.Events, now, time.Duration(context.Int("stale-days"))*time.Hour*24)
+	if context.Bool("json") {
+		return lp.PrintIndentedJSON(profile)
+	}
+
+	var out string
+	out = out + fmt.Sprintf("# %v\n", user.UserName)
+	out = out + fmt.Sprintf("- Full name: %v\n", user.FullName)
+	out = out + fmt.Sprintf("- Admin: %v\n", user.IsAdmin)
+	out = out + fmt.Sprintf("- Disabled: %v\n", user.Disabled)
+	out = out + fmt.Sprintf("- MFA: %v\n", user.Multifactor)
+	out = out + fmt.Sprintf("- Created: %v\n", user.Created)
+	if profile.LastLogin != nil {
+		out = out + fmt.Sprintf("- Last login: %v\n", profile.LastLogin.In(location))
+	} else {
+		out = out + fmt.Sprintf("- Last login: %v\n", user.LastLogin)
+	}
+	out = out + fmt.Sprintf("- Groups: %v\n", strings.Join(user.Groups, ", "))
+
+	out = out + fmt.Sprintf("\n# Flags\n")
+	for _, f := range profile.Flags {
+		out = out + fmt.Sprintf("- %v\n", f)
+	}
+
+	out = out + fmt.Sprintf("\n# Shared Folders\n")
+	for _, f := range profile.SharedFolders {
+		permissions := []string{}
+		if f.ReadOnly {
+			permissions = append(permissions, "read-only")
+		}
+		if f.Give {
+			permissions = append(permissions, "give")
+		}
+		if f.CanAdminister {
+			permissions = append(permissions, "administer")
+		}
+		out = out + fmt.Sprintf("- %v [%v]\n", f.SharedFolderName, strings.Join(permissions, ", "))
+	}
+
+	out = out + fmt.Sprintf("\n# Activities in %d day(s)\n", context.Int("days"))
+	for _, event := range profile.Events {
+		out = out + fmt.Sprintf("%v\n", event.String(location))
+	}
+	fmt.Print(out)
 	return nil
 }
 
@@ -236,12 +539,18 @@ var commandGet = cli.Command{
 		subCommandGetUsers,
 		subCommandGetGroups,
 		subCommandGetEvents,
+		subCommandGetMFAReport,
+		subCommandGetViolations,
 	},
 }
 
 func updateLocation(context *cli.Context) (err error) {
-	if context.GlobalString("timezone") != "" {
-		newLoc, err := time.LoadLocation(context.GlobalString("timezone"))
+	timezone := context.GlobalString("timezone")
+	if timezone == "" {
+		timezone = LoadConfigFromContext(context).TimeZone
+	}
+	if timezone != "" {
+		newLoc, err := time.LoadLocation(timezone)
 		if err != nil {
 			return err
 		}
@@ -250,60 +559,264 @@ func updateLocation(context *cli.Context) (err error) {
 	return nil
 }
 
+// timeRangeFlags select the period of events. --from and --to take precedence over --duration.
+var timeRangeFlags = []cli.Flag{
+	cli.StringFlag{Name: "from", Usage: "Start of the period: RFC3339, a date such as 2006-01-02, -36h, -7d, yesterday or last-monday in the configured timezone"},
+	cli.StringFlag{Name: "to", Usage: "End of the period in the same formats as --from (Default: now)"},
+}
+
+// eventReportFlags control how a long period of events is fetched.
+var eventReportFlags = []cli.Flag{
+	cli.StringFlag{Name: "window", Usage: "Fetch events by a request per <duration> such as 6h (Default: event_window in config or 24h)"},
+	cli.IntFlag{Name: "concurrency", Usage: "Maximum number of requests in flight (Default: event_concurrency in config or 4)"},
+}
+
+// eventReportOptionsFromContext returns options given by --window and --concurrency, or by config.
+func eventReportOptionsFromContext(context *cli.Context) lp.EventReportOptions {
+	opts, err := LoadConfigFromContext(context).EventReportOptions()
+	lp.DieIf(err)
+	if context.String("window") != "" {
+		window, err := time.ParseDuration(context.String("window"))
+		if err != nil || window <= 0 {
+			lp.DieIf(errors.Errorf("--window has to be a positive duration such as 6h: %v", context.String("window")))
+		}
+		opts.Window = window
+	}
+	if context.Int("concurrency") > 0 {
+		opts.Concurrency = context.Int("concurrency")
+	}
+	return opts
+}
+
+// eventFilterFlags select fetched events locally. They are shared by `get events` and `get dashboard`.
+var eventFilterFlags = []cli.Flag{
+	cli.StringSliceFlag{Name: "action", Value: &cli.StringSlice{}, Usage: "Only events of <action>: exact, a prefix ending with '*' or a regular expression in '/.../'"},
+	cli.StringSliceFlag{Name: "ip", Value: &cli.StringSlice{}, Usage: "Only events from <ip> address or CIDR"},
+	cli.StringSliceFlag{Name: "user-pattern", Value: &cli.StringSlice{}, Usage: "Only events of users matching <pattern> with '*' wildcards"},
+	cli.StringSliceFlag{Name: "data", Value: &cli.StringSlice{}, Usage: "Only events whose data contains <text>"},
+	cli.StringSliceFlag{Name: "exclude-action", Value: &cli.StringSlice{}, Usage: "Hide events of <action> in the same format as --action"},
+	cli.StringSliceFlag{Name: "exclude-ip", Value: &cli.StringSlice{}, Usage: "Hide events from <ip> address or CIDR"},
+	cli.StringSliceFlag{Name: "exclude-user-pattern", Value: &cli.StringSlice{}, Usage: "Hide events of users matching <pattern>"},
+	cli.StringSliceFlag{Name: "exclude-data", Value: &cli.StringSlice{}, Usage: "Hide events whose data contains <text>"},
+}
+
+// eventFilterFromContext returns the filter given by eventFilterFlags.
+func eventFilterFromContext(context *cli.Context) *lp.EventFilter {
+	filter, err := lp.NewEventFilter(
+		lp.EventMatch{
+			Actions:   context.StringSlice("action"),
+			IPs:       context.StringSlice("ip"),
+			Usernames: context.StringSlice("user-pattern"),
+			Data:      context.StringSlice("data"),
+		},
+		lp.EventMatch{
+			Actions:   context.StringSlice("exclude-action"),
+			IPs:       context.StringSlice("exclude-ip"),
+			Usernames: context.StringSlice("exclude-user-pattern"),
+			Data:      context.StringSlice("exclude-data"),
+		})
+	lp.DieIf(err)
+	return filter
+}
+
+// printEventsTable outputs `fields` of events given by `next` in a table.
+func printEventsTable(w io.Writer, fields []string, next func() (lp.Event, bool)) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(fields, "\t")))
+	for e, ok := next(); ok; e, ok = next() {
+		values := []string{}
+		for _, f := range fields {
+			values = append(values, e.Field(f, location))
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	tw.Flush()
+}
+
+// printEvents outputs events selected by `filter` one by one, either in the same shape as lp.Events
+// or as a table of `fields` if they are given.
+func printEvents(w io.Writer, it *lp.EventIterator, filter *lp.EventFilter, fields []string) error {
+	next := func() (lp.Event, bool) {
+		for it.Next() {
+			if e := it.Event(); filter.Matches(e) {
+				return e, true
+			}
+		}
+		return lp.Event{}, false
+	}
+	if len(fields) != 0 {
+		printEventsTable(w, fields, next)
+		return it.Err()
+	}
+
+	fmt.Fprint(w, "{\n    \"events\": [")
+	for i := 0; ; i++ {
+		e, ok := next()
+		if !ok {
+			break
+		}
+		e.Time = e.Time.In(location)
+		b, err := json.MarshalIndent(e, "        ", "    ")
+		if err != nil {
+			return err
+		}
+		if i != 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, "\n        %s", b)
+	}
+	fmt.Fprint(w, "\n    ]\n}\n")
+	return it.Err()
+}
+
+// timeRangeFromContext returns the period given by --from and --to,
+// or the last `defaultDays` days if --from is not given.
+func timeRangeFromContext(context *cli.Context, defaultDays int) (lp.JSONLastPassTime, lp.JSONLastPassTime) {
+	from, to, err := lp.ParseTimeRange(context.String("from"), context.String("to"), time.Now(), location,
+		time.Duration(defaultDays)*time.Hour*24)
+	lp.DieIf(err)
+	return from, to
+}
+
 var subCommandGetEvents = cli.Command{
 	Name:        "events",
 	Usage:       "get events",
-	Description: "Get LastPass events. By default, it retrieves events of all users within that day.",
-	ArgsUsage:   "[--user, -u <email> | --duration, -d <days> | [--verbose | -v]]",
+	Description: `
+   Get LastPass events. By default, it retrieves events of all users within that day.
+   With --user or --group, events are fetched only for those users and grouped by user.
+   With --follow, new events are polled every --interval and written in JSON Lines until interrupted.
+   With --output cef, leef or syslog, events are written one per line in that format, and with --send or
+   --collector they are sent to a syslog collector over UDP, TCP or TLS instead.
+`,
+	ArgsUsage:   "[[--user, -u <email>]... | [--group, -g <group>]... | --search <text> | --duration, -d <days> | --from <time> [--to <time>] | [--action <action>]... [--exclude-action <action>]... | --fields <fields> | --follow [--interval <duration>] [--state <file>] | --output, -o <format> [--send | --collector <address>] | [--verbose | -v]]",
 	Before: updateLocation,
 	Action:      doGetEvents,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.IntFlag{Name: "duration, d", Value: 1, Usage: "By specifying this, events from d-day ago to today is retrieved."},
-		cli.StringFlag{Name: "user, u", Value: "", Usage: "Specify events for interested users."},
+		cli.StringSliceFlag{Name: "user, u", Value: &cli.StringSlice{}, Usage: "Specify events for interested users. 'API' selects events triggered by API"},
+		cli.StringSliceFlag{Name: "group, g", Value: &cli.StringSlice{}, Usage: "Specify events for members of <group>"},
+		cli.StringFlag{Name: "search", Usage: "Only events matching <text> searched by LastPass"},
+		cli.StringFlag{Name: "fields", Usage: "Output a table of <fields> separated by ',' from " + strings.Join(lp.EventFields, ", ")},
 		cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
-	},
+	}, append(append(append(timeRangeFlags, eventReportFlags...), eventFilterFlags...), append(followFlags, siemFlags...)...)...),
 }
 
 func doGetEvents(c *cli.Context) error {
 	if c.Bool("verbose") {
 		os.Setenv("DEBUG", "1")
 	}
+	if c.Bool("follow") {
+		return doFollowEvents(c)
+	}
 
-	lastPassLoc, _ := time.LoadLocation(lp.LastPassTimeZone)
-	now := time.Now().In(lastPassLoc)
-	dayAgo := now.Add(-time.Duration(c.Int("duration")) * time.Hour * 24)
-	from := lp.JSONLastPassTime{JSONTime: dayAgo}
-	to := lp.JSONLastPassTime{JSONTime: now}
+	from, to := timeRangeFromContext(c, c.Int("duration"))
+	client := NewLastPassClientFromContext(c)
+	s := lp.NewEventService(client)
+	opts := eventReportOptionsFromContext(c)
 
-	var events *lp.Events
-	var err error
-	s := lp.NewEventService(NewLastPassClientFromContext(c))
-	switch user := c.String("user"); strings.ToLower(user) {
-	case "":
-		events, err = s.GetEventReport(user, "", from, to)
-		err = errors.Wrapf(err, "Failed executing %T.GetEventReport", s)
-	case "api":
-		events, err = s.GetAPIEventReports(from, to)
-		err = errors.Wrapf(err, "Failed executing %T.GetAPIEventReports", s)
-	default:
-		events, err = s.GetAllEventReports(from, to)
-		err = errors.Wrapf(err, "Failed executing %T.GetAllEventReports", s)
+	filter := eventFilterFromContext(c)
+	var fields []string
+	if c.String("fields") != "" {
+		var err error
+		fields, err = lp.ParseEventFields(c.String("fields"))
+		lp.DieIf(err)
 	}
-	if c.String("user") == "" {
-		events, err = s.GetAllEventReports(from, to)
-		err = errors.Wrapf(err, "Failed executing %T.GetAllEventReports", s)
+
+	out := eventOutputFromContext(c)
+	if out != nil {
+		defer out.close()
 	}
-	lp.DieIf(err)
 
-	events.ConvertTimezone(location)
-	lp.PrintIndentedJSON(events)
-	return err
+	usernames := selectEventUsers(c, lp.NewUserService(client))
+	if len(usernames) == 0 {
+		it := s.IterateEventReports("allusers", c.String("search"), from, to, opts)
+		defer it.Close()
+		if out != nil {
+			for it.Next() {
+				if e := it.Event(); filter.Matches(e) {
+					lp.DieIf(out.write(e))
+				}
+			}
+			lp.DieIf(errors.Wrapf(it.Err(), "Failed executing %T.IterateEventReports", s))
+			return nil
+		}
+		err := printEvents(os.Stdout, it, filter, fields)
+		lp.DieIf(errors.Wrapf(err, "Failed executing %T.IterateEventReports", s))
+		return nil
+	}
+
+	results, err := s.GetEventReportsOf(usernames, c.String("search"), from, to, opts)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetEventReportsOf", s))
+	for i := range results {
+		results[i].Events = filter.Apply(results[i].Events)
+		if out != nil {
+			for _, e := range results[i].Events {
+				lp.DieIf(out.write(e))
+			}
+			continue
+		}
+		for j := range results[i].Events {
+			results[i].Events[j].Time = results[i].Events[j].Time.In(location)
+		}
+	}
+	if len(fields) != 0 {
+		events := []lp.Event{}
+		for _, r := range results {
+			events = append(events, r.Events...)
+		}
+		i := 0
+		printEventsTable(os.Stdout, fields, func() (lp.Event, bool) {
+			if i == len(events) {
+				return lp.Event{}, false
+			}
+			i++
+			return events[i-1], true
+		})
+		return nil
+	}
+	return lp.PrintIndentedJSON(struct {
+		Users []lp.UserEvents `json:"users"`
+	}{Users: results})
+}
+
+// selectEventUsers returns users given by --user and members of groups given by --group without duplicates.
+func selectEventUsers(c *cli.Context, s *lp.UserService) []string {
+	seen := make(map[string]bool)
+	usernames := []string{}
+	add := func(name string) {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			usernames = append(usernames, name)
+		}
+	}
+	for _, u := range c.StringSlice("user") {
+		add(u)
+	}
+	if groups := c.StringSlice("group"); len(groups) != 0 {
+		users, err := s.GetAllUsers()
+		lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+		members := lp.GroupMembers(users)
+		for _, g := range groups {
+			if _, ok := members[g]; !ok {
+				lp.DieIf(errors.Errorf("Group %v does not exist", g))
+			}
+			for _, u := range members[g] {
+				add(u.UserName)
+			}
+		}
+	}
+	return usernames
 }
 
 var subCommandGetGroups = cli.Command{
-	Name:   "groups",
-	Usage:  "get groups",
-	Action: doGetGroups,
+	Name:        "groups",
+	Usage:       "get groups",
+	ArgsUsage:   "[--json]",
+	Description: "List groups with number of members, MFA coverage and disabled members.",
+	Action:      doGetGroups,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "json", Usage: "Output in JSON format"},
+	},
 }
 
 // There are no API that fetches group info
@@ -313,19 +826,17 @@ func doGetGroups(context *cli.Context) error {
 	users, err := s.GetAllUsers()
 	lp.DieIf(errors.Wrap(err, "Failed executing doGetGroups()"))
 
-	deps := make(map[string]bool)
-	for _, u := range users {
-		for _, group := range u.Groups {
-			if _, ok := deps[group]; !ok {
-				deps[group] = true
-			}
-		}
+	groups := lp.SummarizeGroups(users)
+	if context.Bool("json") {
+		return lp.PrintIndentedJSON(groups)
 	}
 
-	for dep := range deps {
-		fmt.Println(dep)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tMEMBERS\tMFA\tDISABLED")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%v\t%d\t%d (%.1f%%)\t%d\n", g.Name, g.NumberOfMembers, g.MFAEnabled, g.MFAPercentage, g.Disabled)
 	}
-	return nil
+	return w.Flush()
 }
 
 var subCommandGetUsers = cli.Command{
@@ -368,6 +879,50 @@ func doGetUsers(context *cli.Context) (err error) {
 	return nil
 }
 
+var subCommandGetMFAReport = cli.Command{
+	Name:        "mfa",
+	Usage:       "get MFA coverage report",
+	ArgsUsage:   "[--json]",
+	Description: "Break MFA adoption down by factor type per group. Factors outside `allowed_mfa_factors` in config are flagged.",
+	Action:      doGetMFAReport,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "json", Usage: "Output in JSON format"},
+	},
+}
+
+func doGetMFAReport(context *cli.Context) error {
+	config := LoadConfigFromContext(context)
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrap(err, "Failed executing GetAllUsers()"))
+
+	report := lp.NewMFAReport(users, config.AllowedMFAFactors)
+	if context.Bool("json") {
+		return lp.PrintIndentedJSON(report)
+	}
+
+	var out string
+	out = out + fmt.Sprintf("# Admins without MFA\n")
+	for _, u := range report.AdminsWithoutMFA {
+		out = out + fmt.Sprintf("- %v\n", u)
+	}
+
+	out = out + fmt.Sprintf("\n# Disallowed Factors\n")
+	for _, u := range report.DisallowedFactors {
+		out = out + fmt.Sprintf("- %v (%v)\n", u.UserName, u.Factor)
+	}
+
+	out = out + fmt.Sprintf("\n# MFA Coverage\n")
+	for _, c := range append([]lp.MFACoverage{report.Overall}, report.Groups...) {
+		out = out + fmt.Sprintf("## %v: %d/%d (%.1f%%)\n", c.Group, c.Enabled, c.Total, c.Percentage)
+		for _, f := range c.SortedFactors() {
+			out = out + fmt.Sprintf("- %v: %d (%.1f%%)\n", f, c.Factors[f], c.FactorPercentage(f))
+		}
+	}
+	fmt.Print(out)
+	return nil
+}
+
 var commandCreate = cli.Command{
 	Name:  "create",
 	Usage: "Create a new object",
@@ -379,18 +934,28 @@ var commandCreate = cli.Command{
 var subCommandCreateUser = cli.Command{
 	Name:        "user",
 	Usage:       "create an users",
-	ArgsUsage:   "[--bulk | -b <file>] [--dept | -d <department>] <username>",
-	Description: `Create one or more users specifying either username or pre-configured file.`,
+	ArgsUsage:   "[--bulk | -b <file> [--format <format>]] [--dept | -d <department>] [--fullname <name>] [[--attr <key=value>]...] [--duo-username <name>] [--require-password-change] [--upsert] [--chunk-size <n>] [--checkpoint <file>] [--workers <n>] <username> | --resume <file> [--retry-failed]",
+	Description: `Create one or more users specifying either username or pre-configured file.
+   The file may be JSON({"data":[...]}), JSON Lines, YAML or CSV. CSV needs a header with
+   username, fullname, groups(separated by ';') and attr:<name> columns for custom attributes.`,
 	Action:      doAddUser,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{Name: "email, e", Value: "", Usage: "Create user with <email>"},
 		cli.StringSliceFlag{Name: "dept, d", Value: &cli.StringSlice{}, Usage: "Create user with <email> in <department>"},
-		cli.StringFlag{Name: "bulk, b", Value: "", Usage: "Load users from a JSON <file>"},
-	},
+		cli.StringFlag{Name: "fullname", Usage: "Full name of the user"},
+		cli.StringSliceFlag{Name: "attr", Value: &cli.StringSlice{}, Usage: "Custom attribute in <key=value>"},
+		cli.StringFlag{Name: "duo-username", Usage: "Duo Security username of the user"},
+		cli.BoolFlag{Name: "require-password-change", Usage: "Require the user to change the master password at first login"},
+		cli.StringFlag{Name: "bulk, b", Value: "", Usage: "Load users from a <file>"},
+		cli.StringFlag{Name: "format", Value: "", Usage: "Format of bulk file: json, jsonl, yaml or csv (Default: auto-detect)"},
+		cli.BoolFlag{Name: "upsert", Usage: "Update users who already exist instead of skipping them"},
+		cli.IntFlag{Name: "chunk-size", Usage: "Number of users sent in a request (Default: batch_size in config or 100)"},
+		cli.BoolFlag{Name: "force", Usage: "Create users even if separation-of-duties rules are violated"},
+	}, bulkFlags...),
 }
 
 func doAddUser(context *cli.Context) error {
-	if context.String("bulk") != "" {
+	if isBulk(context) {
 		return doAddUsersInBulk(context)
 	}
 
@@ -399,72 +964,139 @@ func doAddUser(context *cli.Context) error {
 		lp.DieIf(errors.New("Email(username) has to be specified"))
 	}
 
+	attrs, err := parseAttributes(context.StringSlice("attr"))
+	lp.DieIf(err)
 	user := lp.User{
-		UserName: argUserName,
-		Groups:   context.StringSlice("dept"),
+		UserName:              argUserName,
+		FullName:              context.String("fullname"),
+		Groups:                context.StringSlice("dept"),
+		Attributes:            attrs,
+		Duousername:           context.String("duo-username"),
+		PasswordResetRequired: context.Bool("require-password-change"),
 	}
+	return addUsers(context, []lp.User{user})
+}
 
-	c := NewLastPassClientFromContext(context)
-	err := lp.NewUserService(c).BatchAdd([]lp.User{user})
-	lp.DieIf(errors.Wrap(err, "Failed executing NewUserService."))
-
-	message := user.UserName
-	for _, dep := range user.Groups {
-		message += fmt.Sprintf(" in %v", dep)
+// parseAttributes parses custom attributes given in <key=value> format.
+func parseAttributes(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
 	}
-	lp.Log("created", message)
-	return nil
+	attrs := make(map[string]string)
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("Attribute has to be in <key=value> format: %v", pair)
+		}
+		attrs[kv[0]] = kv[1]
+	}
+	return attrs, nil
 }
 
 func doAddUsersInBulk(context *cli.Context) error {
-	users, err := loadAddingUsers(context.String("bulk"))
-	if err != nil {
-		lp.DieIf(err)
+	cp := openCheckpoint(context, "create-users", func() ([]lp.BulkItem, map[string]string) {
+		items := []lp.BulkItem{}
+		for _, u := range loadUsersFile(context) {
+			u := u
+			items = append(items, lp.BulkItem{UserName: u.UserName, User: &u})
+		}
+		return items, map[string]string{"upsert": fmt.Sprint(context.Bool("upsert"))}
+	})
+	upsert := cp.Options["upsert"] == "true"
+	chunkSize := LoadConfigFromContext(context).BatchSize
+	if context.Int("chunk-size") > 0 {
+		chunkSize = context.Int("chunk-size")
 	}
 
-	c := NewLastPassClientFromContext(context)
-	err = lp.NewUserService(c).BatchAdd(users)
-	lp.DieIf(errors.Wrap(err, "Failed executing BatchAdd."))
+	pending := cp.Pending(context.Bool("retry-failed"))
+	users := []lp.User{}
+	for _, i := range pending {
+		users = append(users, *cp.Items[i].User)
+	}
+	if rules := loadSoDRules(context); rules != nil {
+		refuseSoDViolations(context, rules.Evaluate(users))
+	}
 
-	for _, user := range users {
-		message := user.UserName
-		for _, dep := range user.Groups {
-			message += fmt.Sprintf(" in %v", dep)
+	c := NewLastPassClientFromContext(context)
+	s := lp.NewUserService(c)
+	classified, err := s.ClassifyForBatchAdd(users, upsert)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.ClassifyForBatchAdd", s))
+	expected := make(map[string]lp.UserResult)
+	skipping := []int{}
+	skipped := []lp.UserResult{}
+	for j, r := range classified {
+		if r.Result == lp.Skipped {
+			skipping = append(skipping, pending[j])
+			skipped = append(skipped, r)
+			continue
 		}
-		lp.Log("created", message)
+		expected[strings.ToLower(r.UserName)] = r
 	}
-
-	return err
+	lp.DieIf(errors.Wrapf(cp.Record(skipping, skipped), "Failed saving checkpoint %v", cp.Path()))
+
+	return runBulk(context, cp, chunkSize, func(items []lp.BulkItem) []lp.UserResult {
+		chunk := []lp.User{}
+		results := []lp.UserResult{}
+		for _, item := range items {
+			chunk = append(chunk, *item.User)
+			results = append(results, expected[strings.ToLower(item.UserName)])
+		}
+		return lp.NewUserService(c).BatchAddChunk(chunk, results)
+	})
 }
 
-func loadAddingUsers(usersFile string) (config []lp.User, err error) {
-	f, err := ioutil.ReadFile(usersFile)
-	if err != nil {
-		return
+// addUsers sends users by chunked batchadd and prints outcome of each user.
+func addUsers(context *cli.Context, users []lp.User) error {
+	opts := lp.BatchAddOptions{
+		ChunkSize: LoadConfigFromContext(context).BatchSize,
+		Upsert:    context.Bool("upsert"),
+	}
+	if context.Int("chunk-size") > 0 {
+		opts.ChunkSize = context.Int("chunk-size")
 	}
 
-	data := struct {
-		Data []lp.User `json:"data"`
-	}{}
+	if rules := loadSoDRules(context); rules != nil {
+		refuseSoDViolations(context, rules.Evaluate(users))
+	}
 
-	if err = json.Unmarshal(f, &data); err != nil {
-		return
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	results, err := s.BatchAddInChunks(users, opts)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.BatchAddInChunks", s))
+
+	printUserResults(results)
+	failed := 0
+	for _, r := range results {
+		if r.Result == lp.Failed {
+			failed++
+		}
 	}
-	config = data.Data
-	return
+	if failed != 0 {
+		lp.DieIf(errors.Errorf("%d of %d user(s) failed", failed, len(results)))
+	}
+	return nil
+}
+
+// printUserResults outputs outcome of each user in a table.
+func printUserResults(results []lp.UserResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tRESULT\tREASON")
+	for _, r := range results {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", r.UserName, r.Result, r.Reason)
+	}
+	w.Flush()
 }
 
 var subCommandDashboards = cli.Command{
 	Name:        "dashboard",
 	Usage:       "Report summary",
-	ArgsUsage:   "[--verbose | -v] [--period | -d <duration>]",
+	ArgsUsage:   "[--verbose | -v] [--period | -d <duration> | --from <time> [--to <time>]]",
 	Description: `show audit related dashboard`,
 	Action:      doDashboard,
 	Before: updateLocation,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.IntFlag{Name: "duration, d", Usage: "Audits for past <duration> day"},
 		cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
-	},
+	}, append(append(timeRangeFlags, eventReportFlags...), eventFilterFlags...)...),
 }
 
 func doDashboard(context *cli.Context) error {
@@ -476,6 +1108,8 @@ func doDashboard(context *cli.Context) error {
 	if context.Int("duration") >= 1 {
 		durationToAuditInDay = context.Int("duration")
 	}
+	from, to := timeRangeFromContext(context, durationToAuditInDay)
+	filter := eventFilterFromContext(context)
 
 	c := NewLastPassClientFromContext(context)
 
@@ -493,7 +1127,7 @@ func doDashboard(context *cli.Context) error {
 	wg.Add(numOfGoRoutines)
 	go getAllUsers(&wg, lp.NewUserService(c), c1)
 	go getSharedFolders(&wg, lp.NewFolderService(c), c2)
-	go getEvents(&wg, lp.NewEventService(c), c3, time.Duration(durationToAuditInDay))
+	go getEvents(&wg, lp.NewEventService(c), c3, from, to, eventReportOptionsFromContext(context))
 	for i := 0; i < numOfGoRoutines; i++ {
 		select {
 		case users := <-c1:
@@ -508,7 +1142,7 @@ func doDashboard(context *cli.Context) error {
 			}
 		case folders = <-c2:
 		case es := <-c3:
-			events = es.Events
+			events = es.Filter(filter).Events
 		}
 	}
 	wg.Wait()
@@ -601,16 +1235,10 @@ func getAllUsers(wg *sync.WaitGroup, s *lp.UserService, q chan []lp.User) {
 	q <- users
 }
 
-func getEvents(wg *sync.WaitGroup, s *lp.EventService, q chan *lp.Events, d time.Duration) {
+func getEvents(wg *sync.WaitGroup, s *lp.EventService, q chan *lp.Events, from, to lp.JSONLastPassTime, opts lp.EventReportOptions) {
 	defer wg.Done()
-	loc, _ := time.LoadLocation(lp.LastPassTimeZone)
-	now := time.Now().In(loc)
-	dayAgo := now.Add(-d * time.Hour * 24)
-
-	from := lp.JSONLastPassTime{JSONTime: dayAgo}
-	to := lp.JSONLastPassTime{JSONTime: now}
-	events, err := s.GetAllEventReports(from, to)
-	lp.DieIf(errors.Wrap(err, "Failed executing GetAllEventReports."))
+	events, err := s.IterateEventReports("allusers", "", from, to, opts).Collect()
+	lp.DieIf(errors.Wrap(err, "Failed executing IterateEventReports."))
 	q <- events
 }
 
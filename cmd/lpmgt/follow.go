@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	lp "lpmgt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// followFlags make `get events` keep polling new events.
+var followFlags = []cli.Flag{
+	cli.BoolFlag{Name: "follow, F", Usage: "Keep polling and output new events in JSON Lines"},
+	cli.StringFlag{Name: "interval", Value: "1m", Usage: "Poll every <duration> with --follow"},
+	cli.StringFlag{Name: "overlap", Value: lp.DefaultFollowOverlap.String(), Usage: "Refetch <duration> before the last event or poll to catch events recorded late"},
+	cli.StringFlag{Name: "state", Usage: "Save the last event seen to state <file> (Default: ~/.lpmgt/follow.json)"},
+}
+
+// doFollowEvents polls events until SIGINT or SIGTERM, writing new ones to stdout in JSON Lines
+// or in the format given by --output.
+// The state is saved after each batch is written, so a restarted follower continues where it stopped.
+func doFollowEvents(c *cli.Context) error {
+	if len(c.StringSlice("user")) != 0 || len(c.StringSlice("group")) != 0 {
+		lp.DieIf(errors.New("--follow cannot be combined with --user or --group. Use --user-pattern instead"))
+	}
+	interval, err := time.ParseDuration(c.String("interval"))
+	if err != nil || interval <= 0 {
+		lp.DieIf(errors.Errorf("--interval has to be a positive duration such as 30s: %v", c.String("interval")))
+	}
+	overlap, err := time.ParseDuration(c.String("overlap"))
+	if err != nil || overlap <= 0 {
+		lp.DieIf(errors.Errorf("--overlap has to be a positive duration such as 10m: %v", c.String("overlap")))
+	}
+	var fields []string
+	if c.String("fields") != "" {
+		fields, err = lp.ParseEventFields(c.String("fields"))
+		lp.DieIf(err)
+	}
+
+	stateFile := c.String("state")
+	if stateFile == "" {
+		stateFile = lp.DefaultFollowStateFile()
+	}
+	state, err := lp.LoadFollowState(stateFile)
+	lp.DieIf(errors.Wrapf(err, "Failed loading state %v", stateFile))
+
+	follower := &lp.EventFollower{
+		Service: lp.NewEventService(NewLastPassClientFromContext(c)),
+		Search:  c.String("search"),
+		Filter:  eventFilterFromContext(c),
+		Overlap: overlap,
+		Options: eventReportOptionsFromContext(c),
+		State:   state,
+	}
+	if c.String("from") != "" {
+		follower.Start, err = lp.ParseTime(c.String("from"), time.Now(), location)
+		lp.DieIf(err)
+	}
+
+	out := eventOutputFromContext(c)
+	if out != nil {
+		defer out.close()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	encoder := json.NewEncoder(os.Stdout)
+	for {
+		polled := state.Copy()
+		events, err := follower.Poll()
+		if err != nil {
+			// Keep running as a sidecar. The same period is polled again next time.
+			lp.Log("warning", fmt.Sprintf("Failed polling events: %v", err))
+		}
+		for _, e := range events {
+			if out == nil {
+				lp.DieIf(encoder.Encode(projectEvent(e, fields)))
+				continue
+			}
+			if err = out.write(e); err != nil {
+				// Put the state back, so the same batch is polled and sent again next time.
+				// Events sent before the failure are sent twice.
+				lp.Log("warning", fmt.Sprintf("%v. Retrying in %v", err, interval))
+				*state = *polled
+				break
+			}
+		}
+		if err == nil {
+			lp.DieIf(errors.Wrapf(state.Save(), "Failed saving state %v", stateFile))
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// projectEvent returns the event in `location`, or only its `fields` if they are given.
+func projectEvent(e lp.Event, fields []string) interface{} {
+	if len(fields) == 0 {
+		e.Time = e.Time.In(location)
+		return e
+	}
+	projected := make(map[string]string)
+	for _, f := range fields {
+		projected[f] = e.Field(f, location)
+	}
+	return projected
+}
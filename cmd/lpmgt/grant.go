@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	lp "lpmgt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var commandGrant = cli.Command{
+	Name:  "grant",
+	Usage: "grant temporary access to a group",
+	ArgsUsage: `<email> --group | -g <group> --for <duration>
+   lpmgt grant reap [--dry-run] [--override-protection]
+   lpmgt grant list`,
+	Description: `
+   Add <email> to <group> and record the expiry in a local state file.
+   'reap' removes expired memberships, which is meant to be run by cron.
+   'list' shows grants in the state file.
+`,
+	Before: updateLocation,
+	Action: doGrant,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "group, g", Usage: "Group to be granted"},
+		cli.DurationFlag{Name: "for", Usage: "Duration of the grant such as 4h or 30m"},
+		cli.StringFlag{Name: "state", Usage: "State <file> of grants (Default: grant_state_file in config or ~/.lpmgt/grants.json)"},
+		cli.BoolFlag{Name: "dry-run", Usage: "Show expired grants without removing them"},
+		overrideProtectionFlag,
+	},
+}
+
+// grant has 'reap' and 'list' in place of <email>, since urfave/cli does not parse flags
+// after arguments of a command having subcommands.
+func doGrant(context *cli.Context) error {
+	switch context.Args().Get(0) {
+	case "reap":
+		return doGrantReap(context)
+	case "list":
+		return doGrantList(context)
+	}
+
+	argUserName := context.Args().Get(0)
+	if argUserName == "" {
+		lp.DieIf(errors.New("Email(username) has to be specified"))
+	}
+	group := context.String("group")
+	if group == "" {
+		lp.DieIf(errors.New("--group has to be specified"))
+	}
+	duration := context.Duration("for")
+	if duration <= 0 {
+		lp.DieIf(errors.New("--for has to be a positive duration such as 4h"))
+	}
+
+	store := loadGrantStore(context)
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	user, err := s.GetUserData(argUserName)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetUserData", s))
+
+	now := time.Now()
+	grant := lp.Grant{
+		UserName:  user.UserName,
+		Group:     group,
+		GrantedAt: now,
+		ExpiresAt: now.Add(duration),
+		GrantedBy: lp.OperatorName(),
+	}
+
+	_, granted := store.Find(user.UserName, group)
+	isMember := false
+	for _, g := range user.Groups {
+		if g == group {
+			isMember = true
+		}
+	}
+	// Reaping would take away membership the user had before the grant.
+	if isMember && !granted {
+		lp.DieIf(errors.Errorf("%v is already a member of %v without a grant", user.UserName, group))
+	}
+	if !isMember {
+		_, err = s.ChangeGroupsMembership([]lp.TransferringUser{{UserName: user.UserName, Add: []string{group}}})
+		lp.DieIf(errors.Wrapf(err, "Failed executing %T.ChangeGroupsMembership", s))
+	}
+
+	if isDryRun(context) {
+		return nil
+	}
+	store.Put(grant)
+	lp.DieIf(errors.Wrap(store.Save(), "Failed saving grants"))
+	lp.Log("granted", fmt.Sprintf("%v to %v until %v", user.UserName, group, grant.ExpiresAt.In(location).Format(time.RFC3339)))
+	return nil
+}
+
+func doGrantReap(context *cli.Context) error {
+	store := loadGrantStore(context)
+	expired := store.Expired(time.Now())
+	if len(expired) == 0 {
+		return nil
+	}
+
+	// Grants of protected groups are left to be reaped with --override-protection,
+	// so that they don't block reaping the others.
+	protection := lp.NewProtection(LoadConfigFromContext(context))
+	reapable := []lp.Grant{}
+	skipped := 0
+	for _, g := range expired {
+		if len(protection.ProtectedGroupsIn([]string{g.Group})) != 0 {
+			message := g.UserName + " would leave protected group " + g.Group
+			if !context.Bool("override-protection") {
+				lp.Log("skipped", message+". Use --override-protection to reap it")
+				skipped++
+				continue
+			}
+			lp.Log("warning", message)
+		}
+		reapable = append(reapable, g)
+	}
+
+	changes := []lp.TransferringUser{}
+	for _, g := range reapable {
+		changes = append(changes, lp.TransferringUser{UserName: g.UserName, Del: []string{g.Group}})
+	}
+	printGroupChanges(changes)
+	if isDryRun(context) || len(changes) == 0 {
+		if skipped != 0 {
+			lp.DieIf(errors.Errorf("%d grant(s) of protected groups were skipped", skipped))
+		}
+		return nil
+	}
+
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	results := s.ChangeGroupsMembershipInChunks(changes, LoadConfigFromContext(context).BatchSize)
+	failed := 0
+	for i, r := range results {
+		if r.Result == lp.Failed {
+			failed++
+			continue
+		}
+		store.Remove(reapable[i])
+	}
+	lp.DieIf(errors.Wrap(store.Save(), "Failed saving grants"))
+	fmt.Println()
+	printUserResults(results)
+
+	if skipped != 0 {
+		lp.DieIf(errors.Errorf("%d grant(s) failed to be reaped and %d grant(s) of protected groups were skipped", failed, skipped))
+	}
+	if failed != 0 {
+		lp.DieIf(errors.Errorf("%d of %d grant(s) failed to be reaped", failed, len(results)))
+	}
+	return nil
+}
+
+func doGrantList(context *cli.Context) error {
+	store := loadGrantStore(context)
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tGROUP\tGRANTED BY\tGRANTED AT\tEXPIRES AT\tEXPIRED")
+	for _, g := range store.Grants {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", g.UserName, g.Group, g.GrantedBy,
+			g.GrantedAt.In(location).Format(time.RFC3339), g.ExpiresAt.In(location).Format(time.RFC3339), g.IsExpired(now))
+	}
+	return w.Flush()
+}
+
+func loadGrantStore(context *cli.Context) *lp.GrantStore {
+	path := context.String("state")
+	if path == "" {
+		path = LoadConfigFromContext(context).GrantStateFile
+	}
+	if path == "" {
+		path = lp.DefaultGrantStateFile()
+	}
+	store, err := lp.LoadGrantStore(path)
+	lp.DieIf(errors.Wrapf(err, "Failed loading grants from %v", path))
+	return store
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	lp "lpmgt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// Group command with subcommands.
+// LastPass has no API for groups, so they are changed through membership of all members.
+var commandGroup = cli.Command{
+	Name:    "group",
+	Aliases: []string{"groups"},
+	Usage:   "rename, merge, split or sync groups",
+	Subcommands: []cli.Command{
+		subCommandGroupRename,
+		subCommandGroupMerge,
+		subCommandGroupSplit,
+		subCommandGroupRollback,
+		subCommandGroupSyncDynamic,
+	},
+}
+
+// groupChangeFlags are common flags of commands changing membership of groups.
+var groupChangeFlags = []cli.Flag{
+	cli.BoolFlag{Name: "dry-run", Usage: "Show changes without sending them"},
+	cli.StringFlag{Name: "rollback-file", Usage: "Save changes undoing this operation to <file> (Default: group-rollback-<timestamp>.json)"},
+	cli.IntFlag{Name: "chunk-size", Usage: "Number of users sent in a request (Default: batch_size in config or 100)"},
+	overrideProtectionFlag,
+}
+
+var subCommandGroupRename = cli.Command{
+	Name:      "rename",
+	Usage:     "rename group <old> to <new>",
+	ArgsUsage: "[--dry-run] [--rollback-file <file>] [--override-protection] <old> <new>",
+	Action:    doGroupRename,
+	Flags:     groupChangeFlags,
+}
+
+func doGroupRename(context *cli.Context) error {
+	from, to := context.Args().Get(0), context.Args().Get(1)
+	if from == "" || to == "" {
+		lp.DieIf(errors.New("Both <old> and <new> group names have to be specified"))
+	}
+
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+
+	changes, err := lp.PlanGroupRename(users, from, to)
+	lp.DieIf(err)
+	return applyGroupChanges(context, s, changes)
+}
+
+var subCommandGroupMerge = cli.Command{
+	Name:      "merge",
+	Usage:     "merge groups into group <c>",
+	ArgsUsage: "[--dry-run] [--rollback-file <file>] [--override-protection] <a> <b>... --into <c>",
+	Action:    doGroupMerge,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "into", Usage: "Group which members are merged into"},
+	}, groupChangeFlags...),
+}
+
+func doGroupMerge(context *cli.Context) error {
+	sources := []string(context.Args())
+	if len(sources) < 2 {
+		lp.DieIf(errors.New("At least 2 groups have to be specified"))
+	}
+	into := context.String("into")
+	if into == "" {
+		lp.DieIf(errors.New("--into has to be specified"))
+	}
+
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+
+	changes, err := lp.PlanGroupMerge(users, sources, into)
+	lp.DieIf(err)
+	return applyGroupChanges(context, s, changes)
+}
+
+var subCommandGroupSplit = cli.Command{
+	Name:      "split",
+	Usage:     "split group <g> by a mapping file",
+	ArgsUsage: "[--dry-run] [--rollback-file <file>] [--override-protection] <g> --by-file <mapping.csv>",
+	Description: `
+   Move members of <g> to groups given in a CSV file with 'username' and 'group' columns.
+   Every member of <g> has to be in the file. Members mapped to <g> itself stay.
+`,
+	Action: doGroupSplit,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "by-file", Usage: "CSV <file> mapping username to the new group"},
+	}, groupChangeFlags...),
+}
+
+func doGroupSplit(context *cli.Context) error {
+	from := context.Args().Get(0)
+	if from == "" {
+		lp.DieIf(errors.New("Group name has to be specified"))
+	}
+	if context.String("by-file") == "" {
+		lp.DieIf(errors.New("--by-file has to be specified"))
+	}
+	mapping, err := loadGroupMapping(context.String("by-file"))
+	lp.DieIf(err)
+
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+
+	changes, err := lp.PlanGroupSplit(users, from, mapping)
+	lp.DieIf(err)
+	return applyGroupChanges(context, s, changes)
+}
+
+var subCommandGroupSyncDynamic = cli.Command{
+	Name:      "sync-dynamic",
+	Usage:     "sync members of dynamic groups defined in config",
+	ArgsUsage: "[--dry-run] [--rollback-file <file>] [--override-protection]",
+	Description: `
+   Add users matching rules under 'dynamic_groups:' in config to the groups,
+   and remove members who no longer match.
+`,
+	Action: doGroupSyncDynamic,
+	Flags:  groupChangeFlags,
+}
+
+func doGroupSyncDynamic(context *cli.Context) error {
+	config := LoadConfigFromContext(context)
+	if len(config.DynamicGroups) == 0 {
+		lp.DieIf(errors.New("No dynamic groups are defined in config"))
+	}
+
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+
+	changes, err := lp.PlanDynamicGroupSync(users, config.DynamicGroups)
+	lp.DieIf(err)
+	return applyGroupChanges(context, s, changes)
+}
+
+var subCommandGroupRollback = cli.Command{
+	Name:      "rollback",
+	Usage:     "apply a rollback file written by rename, merge or split",
+	ArgsUsage: "[--dry-run] [--override-protection] <file>",
+	Action:    doGroupRollback,
+	Flags:     groupChangeFlags,
+}
+
+func doGroupRollback(context *cli.Context) error {
+	file := context.Args().Get(0)
+	if file == "" {
+		lp.DieIf(errors.New("Rollback file has to be specified"))
+	}
+	b, err := ioutil.ReadFile(file)
+	lp.DieIf(err)
+	var changes []lp.TransferringUser
+	lp.DieIf(errors.Wrapf(json.Unmarshal(b, &changes), "Failed reading %v", file))
+
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	return applyGroupChanges(context, s, changes)
+}
+
+// applyGroupChanges previews changes, sends them in chunks, then writes a rollback file for succeeded ones.
+func applyGroupChanges(context *cli.Context, s *lp.UserService, changes []lp.TransferringUser) error {
+	if len(changes) == 0 {
+		lp.Log("skipped", "nothing to change")
+		return nil
+	}
+	printGroupChanges(changes)
+	for _, c := range changes {
+		refuseLeavingProtectedGroups(context, c.UserName, c.Del)
+	}
+	if isDryRun(context) {
+		return nil
+	}
+
+	chunkSize := LoadConfigFromContext(context).BatchSize
+	if context.Int("chunk-size") > 0 {
+		chunkSize = context.Int("chunk-size")
+	}
+	results := s.ChangeGroupsMembershipInChunks(changes, chunkSize)
+
+	rollback := []lp.TransferringUser{}
+	failed := 0
+	for i, r := range results {
+		if r.Result == lp.Failed {
+			failed++
+			continue
+		}
+		rollback = append(rollback, changes[i].Reverse())
+	}
+	fmt.Println()
+	printUserResults(results)
+
+	if len(rollback) != 0 {
+		rollbackFile := context.String("rollback-file")
+		if rollbackFile == "" {
+			rollbackFile = fmt.Sprintf("group-rollback-%v.json", time.Now().UTC().Format("20060102T150405Z"))
+		}
+		b, err := lp.IndentedJSON(rollback)
+		lp.DieIf(err)
+		lp.DieIf(errors.Wrapf(ioutil.WriteFile(rollbackFile, b, 0600), "Failed writing rollback to %v", rollbackFile))
+		lp.Log("saved", fmt.Sprintf("%v (run `lpmgt group rollback %v` to undo)", rollbackFile, rollbackFile))
+	}
+
+	if failed != 0 {
+		lp.DieIf(errors.Errorf("%d of %d user(s) failed", failed, len(results)))
+	}
+	return nil
+}
+
+// printGroupChanges outputs groups to be added to or deleted from each user.
+func printGroupChanges(changes []lp.TransferringUser) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tADD\tDEL")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", c.UserName, strings.Join(c.Add, ", "), strings.Join(c.Del, ", "))
+	}
+	w.Flush()
+}
+
+// loadGroupMapping reads a CSV file with username and group columns.
+func loadGroupMapping(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed reading header of %v", file)
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+	userColumn, ok1 := columns["username"]
+	groupColumn, ok2 := columns["group"]
+	if !ok1 || !ok2 || len(columns) != 2 {
+		return nil, errors.Errorf("%v: columns have to be 'username' and 'group'", file)
+	}
+
+	mapping := make(map[string]string)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed reading %v", file)
+		}
+		line, _ := r.FieldPos(0)
+		username, group := strings.TrimSpace(row[userColumn]), strings.TrimSpace(row[groupColumn])
+		if username == "" || group == "" {
+			return nil, errors.Errorf("%v: line %d: username and group are required", file, line)
+		}
+		if g, ok := mapping[username]; ok && g != group {
+			return nil, errors.Errorf("%v: line %d: %v is mapped to both %v and %v", file, line, username, g, group)
+		}
+		mapping[username] = group
+	}
+	return mapping, nil
+}
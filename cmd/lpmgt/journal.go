@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	lp "lpmgt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// NewJournalFromContext returns the journal at journal_file in config or ~/.lpmgt/journal.jsonl.
+func NewJournalFromContext(context *cli.Context) *lp.Journal {
+	path := LoadConfigFromContext(context).JournalFile
+	if path == "" {
+		path = lp.DefaultJournalFile()
+	}
+	return lp.NewJournal(path, context.GlobalString("config"), strings.Join(os.Args, " "))
+}
+
+var commandHistory = cli.Command{
+	Name:        "history",
+	Usage:       "show mutating operations recorded in the local journal",
+	ArgsUsage:   "[--limit | -n <number>] [--json]",
+	Description: "Show the most recent operations recorded in journal_file in config or ~/.lpmgt/journal.jsonl.",
+	Before:      updateLocation,
+	Action:      doHistory,
+	Flags: []cli.Flag{
+		cli.IntFlag{Name: "limit, n", Value: 20, Usage: "Number of entries to show. 0 shows all"},
+		cli.BoolFlag{Name: "json", Usage: "Output in JSON format"},
+	},
+}
+
+func doHistory(context *cli.Context) error {
+	entries, err := NewJournalFromContext(context).Entries()
+	lp.DieIf(errors.Wrap(err, "Failed reading journal"))
+	if limit := context.Int("limit"); limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	if context.Bool("json") {
+		return lp.PrintIndentedJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tOS USER\tCOMMAND\tUSERS\tRESULT\tCOMMAND LINE")
+	for _, e := range entries {
+		result := string(e.Result)
+		if e.Error != "" {
+			result = e.Error
+		}
+		users := []string{}
+		for _, u := range e.PriorState {
+			users = append(users, u.UserName)
+		}
+		command := e.Command
+		if e.Undoes != "" {
+			command += fmt.Sprintf(" (undo #%v)", e.Undoes)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", e.ID, e.Time.In(location).Format(time.RFC3339),
+			e.OSUser, command, strings.Join(users, ", "), strings.TrimSpace(result), e.CommandLine)
+	}
+	return w.Flush()
+}
+
+var commandUndo = cli.Command{
+	Name:      "undo",
+	Usage:     "reverse an operation recorded in the local journal",
+	ArgsUsage: "<id>",
+	Description: `
+   Reverse the journal entry <id> shown by 'lpmgt history'.
+   Group changes, updates of existing users and deactivation can be undone.
+`,
+	Action: doUndo,
+}
+
+func doUndo(context *cli.Context) error {
+	id := lp.JournalID(context.Args().Get(0))
+	if id == "" {
+		lp.DieIf(errors.New("Journal entry <id> has to be specified"))
+	}
+
+	c := NewLastPassClientFromContext(context)
+	entry, err := c.Journal.Find(id)
+	lp.DieIf(err)
+	lp.DieIf(errors.Wrapf(lp.UndoJournalEntry(c, entry), "Failed undoing journal entry %v", id))
+	logSent(context, "undone", fmt.Sprintf("#%v %v", entry.ID, entry.Command))
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/urfave/cli"
+	lp "lpmgt"
 	"os"
 )
 
@@ -24,6 +25,18 @@ func main() {
 			Name:  "verbose",
 			Usage: "Verbose output mode",
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print mutating requests with the provisioning hash redacted instead of sending them",
+		},
+		cli.StringFlag{
+			Name:  "propose",
+			Usage: "Write mutating requests to a change request `FILE` to be approved by another operator instead of sending them",
+		},
+		cli.BoolFlag{
+			Name:  "read-only",
+			Usage: "Reject any request mutating LastPass. Can also be enabled by 'read_only: true' in config",
+		},
 	}
 	app.Commands = Commands
 	app.Before = func(context *cli.Context) error {
@@ -32,5 +45,15 @@ func main() {
 		}
 		return nil
 	}
+	app.After = func(context *cli.Context) error {
+		if context.GlobalBool("dry-run") {
+			lp.Log("dry-run", "No mutating request was sent")
+			return nil
+		}
+		if file := context.GlobalString("propose"); file != "" {
+			return saveProposal(context, file)
+		}
+		return nil
+	}
 	app.Run(os.Args)
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	lp "lpmgt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var commandOffboard = cli.Command{
+	Name:      "offboard",
+	Usage:     "offboard a leaving user <email>",
+	ArgsUsage: "[--mode | -m <deleteMode>] [--days <days>] [--evidence <file>] [--override-protection] <email>",
+	Description: `
+   Save the user's record, groups, shared folders and recent events to an evidence file,
+   remove the user from all groups, then delete the user with either 'deactivate(default)', 'remove' or 'delete'.
+   Shared folders whose credentials should be rotated are printed at the end.
+`,
+	Before: updateLocation,
+	Action: doOffboard,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "mode, m", Value: "deactivate", Usage: "deleteMode"},
+		cli.IntFlag{Name: "days", Value: 30, Usage: "Events from <days> ago are saved as evidence"},
+		cli.StringFlag{Name: "evidence", Usage: "Evidence <file> (Default: offboard-<email>-<timestamp>.json)"},
+		overrideProtectionFlag,
+	},
+}
+
+// OffboardingEvidence is a snapshot of the user taken before offboarding.
+type OffboardingEvidence struct {
+	TakenAt       time.Time         `json:"taken_at"`
+	Mode          string            `json:"mode"`
+	User          lp.User           `json:"user"`
+	Groups        []string          `json:"groups"`
+	SharedFolders []lp.SharedFolder `json:"shared_folders"`
+	Events        []lp.Event        `json:"events"`
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._@-]`)
+
+func doOffboard(context *cli.Context) error {
+	argUserName := context.Args().Get(0)
+	if argUserName == "" {
+		lp.DieIf(errors.New("Email(username) has to be specified"))
+	}
+	mode, err := parseDeactivationMode(context.String("mode"))
+	lp.DieIf(err)
+
+	c := NewLastPassClientFromContext(context)
+	us := lp.NewUserService(c)
+
+	// Snapshot
+	user, err := us.GetUserData(argUserName)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetUserData", us))
+	refuseProtectedUser(context, user)
+	refuseLastAdmin(us, user.UserName)
+
+	fs := lp.NewFolderService(c)
+	folders, err := fs.GetSharedFoldersOf(user.UserName)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetSharedFoldersOf", fs))
+
+	loc, _ := time.LoadLocation(lp.LastPassTimeZone)
+	now := time.Now().In(loc)
+	from := lp.JSONLastPassTime{JSONTime: now.AddDate(0, 0, -context.Int("days"))}
+	to := lp.JSONLastPassTime{JSONTime: now}
+	es := lp.NewEventService(c)
+	events, err := es.GetEventReport(user.UserName, "", from, to)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetEventReport", es))
+	events.ConvertTimezone(location)
+
+	evidence := OffboardingEvidence{
+		TakenAt:       now.In(location),
+		Mode:          context.String("mode"),
+		User:          user,
+		Groups:        user.Groups,
+		SharedFolders: folders,
+		Events:        events.Events,
+	}
+	evidenceFile := context.String("evidence")
+	if evidenceFile == "" {
+		evidenceFile = fmt.Sprintf("offboard-%v-%v.json",
+			unsafeFileNameChars.ReplaceAllString(user.UserName, "_"), now.UTC().Format("20060102T150405Z"))
+	}
+	b, err := lp.IndentedJSON(evidence)
+	lp.DieIf(err)
+	if isDryRun(context) {
+		lp.Log("dry-run", fmt.Sprintf("evidence would be saved to %v (not written)", evidenceFile))
+	} else {
+		lp.DieIf(errors.Wrapf(ioutil.WriteFile(evidenceFile, b, 0600), "Failed writing evidence to %v", evidenceFile))
+		lp.Log("saved", evidenceFile)
+	}
+
+	// Leave all groups
+	if len(user.Groups) != 0 {
+		_, err = us.ChangeGroupsMembership([]lp.TransferringUser{{UserName: user.UserName, Del: user.Groups}})
+		lp.DieIf(errors.Wrapf(err, "Failed executing %T.ChangeGroupsMembership", us))
+		for _, g := range user.Groups {
+			logSent(context, "left", fmt.Sprintf("%v from %v", user.UserName, g))
+		}
+	}
+
+	// Delete
+	err = us.DeleteUser(user.UserName, mode)
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.DeleteUser", us))
+	logSent(context, context.String("mode"), user.UserName)
+
+	// Checklist
+	if len(folders) != 0 {
+		fmt.Fprintf(os.Stdout, "\n# Shared folders to rotate credentials\n")
+		for _, f := range folders {
+			fmt.Fprintf(os.Stdout, "- [ ] %v\n", f.ShareFolderName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	lp "lpmgt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var commandOnboard = cli.Command{
+	Name:      "onboard",
+	Usage:     "create users from onboarding templates",
+	ArgsUsage: "[--role | -r <role>] [--fullname <name>] <email> | --file | -f <file>",
+	Description: `
+   Create users with groups and attributes of the template under 'onboarding:' in config.
+   Either a single user with --role, or new hires in a CSV <file> with 'username' and 'role'
+   columns (and optionally 'fullname') can be onboarded. Groups of the templates must exist.
+`,
+	Action: doOnboard,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "role, r", Usage: "Name of the onboarding template"},
+		cli.StringFlag{Name: "fullname", Usage: "Full name of the user"},
+		cli.StringFlag{Name: "file, f", Usage: "Load new hires from a CSV <file>"},
+		cli.BoolFlag{Name: "upsert", Usage: "Update users who already exist instead of skipping them"},
+		cli.IntFlag{Name: "chunk-size", Usage: "Number of users sent in a request (Default: batch_size in config or 100)"},
+		cli.BoolFlag{Name: "force", Usage: "Create users even if separation-of-duties rules are violated"},
+	},
+}
+
+// newHire is a user to be onboarded with the template of `role`.
+type newHire struct {
+	user lp.User
+	role string
+}
+
+func doOnboard(context *cli.Context) error {
+	config := LoadConfigFromContext(context)
+	if len(config.Onboarding) == 0 {
+		lp.DieIf(errors.New("No onboarding templates are defined in config"))
+	}
+
+	var hires []newHire
+	if context.String("file") != "" {
+		var err error
+		hires, err = loadNewHires(context.String("file"))
+		lp.DieIf(err)
+	} else {
+		argUserName := context.Args().Get(0)
+		if argUserName == "" {
+			lp.DieIf(errors.New("Email(username) has to be specified"))
+		}
+		lp.DieIf(lp.ValidateEmail(argUserName))
+		if context.String("role") == "" {
+			lp.DieIf(errors.New("Role has to be specified"))
+		}
+		hires = []newHire{{
+			user: lp.User{UserName: argUserName, FullName: context.String("fullname")},
+			role: context.String("role"),
+		}}
+	}
+
+	c := NewLastPassClientFromContext(context)
+	s := lp.NewUserService(c)
+	existingUsers, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+	existingGroups := lp.GroupMembers(existingUsers)
+
+	// Validate all templates in use before creating anyone.
+	var problems []string
+	for _, role := range usedRoles(hires) {
+		template, ok := config.Onboarding[role]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("onboarding template %v is not defined", role))
+			continue
+		}
+		for _, g := range template.Groups {
+			if _, ok := existingGroups[g]; !ok {
+				problems = append(problems, fmt.Sprintf("group %v in onboarding template %v does not exist", g, role))
+			}
+		}
+	}
+	if len(problems) != 0 {
+		for _, p := range problems {
+			lp.Log("error", p)
+		}
+		lp.DieIf(errors.Errorf("%d problem(s) found in onboarding templates", len(problems)))
+	}
+
+	users := []lp.User{}
+	for _, h := range hires {
+		user := config.Onboarding[h.role].Apply(h.user)
+		users = append(users, user)
+
+		message := fmt.Sprintf("%v as %v: groups %v", user.UserName, h.role, strings.Join(user.Groups, ", "))
+		if len(user.Attributes) != 0 {
+			message += fmt.Sprintf(", attributes %v", user.Attributes)
+		}
+		lp.Log("applied", message)
+	}
+	return addUsers(context, users)
+}
+
+func usedRoles(hires []newHire) []string {
+	seen := make(map[string]bool)
+	roles := []string{}
+	for _, h := range hires {
+		if !seen[h.role] {
+			seen[h.role] = true
+			roles = append(roles, h.role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// loadNewHires reads a CSV file with username, role and optionally fullname columns.
+func loadNewHires(file string) ([]newHire, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed reading header of %v", file)
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		switch column {
+		case "username", "role", "fullname":
+			columns[column] = i
+		default:
+			return nil, errors.Errorf("%v: unknown column %q", file, column)
+		}
+	}
+	for _, required := range []string{"username", "role"} {
+		if _, ok := columns[required]; !ok {
+			return nil, errors.Errorf("%v: column %v is required", file, required)
+		}
+	}
+
+	hires := []newHire{}
+	var problems []string
+	seen := make(map[string]int)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed reading %v", file)
+		}
+		line, _ := r.FieldPos(0)
+
+		h := newHire{
+			user: lp.User{UserName: strings.TrimSpace(row[columns["username"]])},
+			role: strings.TrimSpace(row[columns["role"]]),
+		}
+		if i, ok := columns["fullname"]; ok {
+			h.user.FullName = strings.TrimSpace(row[i])
+		}
+		if err := lp.ValidateEmail(h.user.UserName); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		if h.role == "" {
+			problems = append(problems, fmt.Sprintf("line %d: role is empty", line))
+			continue
+		}
+		name := strings.ToLower(h.user.UserName)
+		if first, ok := seen[name]; ok {
+			problems = append(problems, fmt.Sprintf("line %d: duplicate user %v (first seen at line %d)", line, h.user.UserName, first))
+			continue
+		}
+		seen[name] = line
+		hires = append(hires, h)
+	}
+	if len(problems) != 0 {
+		for _, p := range problems {
+			lp.Log("error", p)
+		}
+		return nil, errors.Errorf("%d error(s) found in %v", len(problems), file)
+	}
+	return hires, nil
+}
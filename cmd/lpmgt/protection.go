@@ -0,0 +1,67 @@
+package main
+
+import (
+	lp "lpmgt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// overrideProtectionFlag allows destructive commands to act on protected users and groups.
+var overrideProtectionFlag = cli.BoolFlag{
+	Name:  "override-protection",
+	Usage: "Act on protected users and groups configured in config",
+}
+
+// refuseProtectedUser exits if `user` is protected, unless --override-protection is given.
+func refuseProtectedUser(context *cli.Context, user lp.User) {
+	reason := lp.NewProtection(LoadConfigFromContext(context)).Reason(user)
+	if reason == "" {
+		return
+	}
+	if context.Bool("override-protection") {
+		lp.Log("warning", reason)
+		return
+	}
+	lp.DieIf(errors.Errorf("%v. Use --override-protection to proceed anyway", reason))
+}
+
+// refuseLeavingProtectedGroups exits if `groups` include protected groups, unless --override-protection is given.
+func refuseLeavingProtectedGroups(context *cli.Context, username string, groups []string) {
+	protected := lp.NewProtection(LoadConfigFromContext(context)).ProtectedGroupsIn(groups)
+	if len(protected) == 0 {
+		return
+	}
+	message := username + " would leave protected group " + strings.Join(protected, ", ")
+	if context.Bool("override-protection") {
+		lp.Log("warning", message)
+		return
+	}
+	lp.DieIf(errors.Errorf("%v. Use --override-protection to proceed anyway", message))
+}
+
+// refuseProtection exits if there are `reasons` for acting on protected users and groups,
+// unless --override-protection is given.
+func refuseProtection(context *cli.Context, reasons []string) {
+	if len(reasons) == 0 {
+		return
+	}
+	if context.Bool("override-protection") {
+		for _, r := range reasons {
+			lp.Log("warning", r)
+		}
+		return
+	}
+	for _, r := range reasons {
+		lp.Log("error", r)
+	}
+	lp.DieIf(errors.Errorf("%d protected user(s) or group(s) would be affected. Use --override-protection to proceed anyway", len(reasons)))
+}
+
+// refuseLastAdmin exits if `username` is the last remaining admin. This cannot be overridden.
+func refuseLastAdmin(s *lp.UserService, username string) {
+	admins, err := s.GetAdminUserData()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAdminUserData", s))
+	lp.DieIf(lp.CheckLastAdmin(admins, username))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	lp "lpmgt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// siemFlags output events in formats which SIEM products ingest, or send them to a syslog collector.
+var siemFlags = []cli.Flag{
+	cli.StringFlag{Name: "output, o", Value: "json", Usage: "Output events in json, cef, leef or syslog"},
+	cli.BoolFlag{Name: "send", Usage: "Send events to the syslog collector at siem.address in config instead of stdout"},
+	cli.StringFlag{Name: "collector", Usage: "Send events to the syslog collector at <address> such as udp://host:514, tcp://host:601 or tls://host:6514"},
+	cli.StringFlag{Name: "ca-file", Usage: "Verify the collector over TLS by certificates in <file> (Default: siem.ca_file in config)"},
+}
+
+// eventOutput writes events in a SIEM format to stdout or a syslog collector.
+type eventOutput struct {
+	formatter *lp.EventFormatter
+	sender    *lp.SyslogSender
+}
+
+// eventOutputFromContext returns eventOutput given by siemFlags, or nil if events are output in JSON.
+func eventOutputFromContext(context *cli.Context) *eventOutput {
+	config := LoadConfigFromContext(context).SIEM
+	address := context.String("collector")
+	if address == "" && context.Bool("send") {
+		address = config.Address
+		if address == "" {
+			lp.DieIf(errors.New("--send requires siem.address in config, or give --collector <address>"))
+		}
+	}
+
+	format := context.String("output")
+	if format == "json" {
+		if address == "" {
+			return nil
+		}
+		if context.IsSet("output") {
+			lp.DieIf(errors.New("JSON cannot be sent to a collector. Choose cef, leef or syslog for --output"))
+		}
+		format = string(lp.OutputSyslog)
+	}
+	if context.String("fields") != "" {
+		lp.DieIf(errors.Errorf("--fields cannot be combined with --output %v", format))
+	}
+	formatter, err := lp.NewEventFormatter(lp.EventOutputFormat(format), config, version)
+	lp.DieIf(err)
+	out := &eventOutput{formatter: formatter}
+
+	if address != "" {
+		caFile := context.String("ca-file")
+		if caFile == "" {
+			caFile = config.CAFile
+		}
+		out.sender, err = lp.NewSyslogSender(address, caFile)
+		lp.DieIf(errors.Wrapf(err, "Failed connecting to collector %v", address))
+	}
+	return out
+}
+
+// write outputs a single event.
+func (o *eventOutput) write(e lp.Event) error {
+	if o.sender != nil {
+		return errors.Wrap(o.sender.Send(o.formatter.SyslogMessage(e)), "Failed sending an event to collector")
+	}
+	_, err := fmt.Fprintln(os.Stdout, o.formatter.FormatEvent(e))
+	return err
+}
+
+// close closes the connection to the collector if any.
+func (o *eventOutput) close() {
+	if o.sender != nil {
+		o.sender.Close()
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	lp "lpmgt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var subCommandGetViolations = cli.Command{
+	Name:        "violations",
+	Usage:       "get separation-of-duties violations",
+	ArgsUsage:   "[--rules <file>] [--json]",
+	Description: "Evaluate separation-of-duties rules against all users. Rules are loaded from --rules or `sod_rules_file` in config.",
+	Action:      doGetViolations,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "rules", Usage: "Rules <file> in YAML format"},
+		cli.BoolFlag{Name: "json", Usage: "Output in JSON format"},
+	},
+}
+
+func doGetViolations(context *cli.Context) error {
+	rules := loadSoDRules(context)
+	if rules == nil {
+		lp.DieIf(errors.New("Rules file has to be specified by --rules or sod_rules_file in config"))
+	}
+
+	s := lp.NewUserService(NewLastPassClientFromContext(context))
+	users, err := s.GetAllUsers()
+	lp.DieIf(errors.Wrapf(err, "Failed executing %T.GetAllUsers", s))
+
+	violations := rules.Evaluate(users)
+	if context.Bool("json") {
+		return lp.PrintIndentedJSON(violations)
+	}
+	for _, v := range violations {
+		fmt.Println(v.String())
+	}
+	return nil
+}
+
+// loadSoDRules loads rules from --rules or sod_rules_file in config.
+// A relative sod_rules_file is taken from the directory of the config file.
+// nil is returned when neither is set.
+func loadSoDRules(context *cli.Context) *lp.SoDRules {
+	path := context.String("rules")
+	if path == "" {
+		path = LoadConfigFromContext(context).SoDRulesFile
+		if path != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(context.GlobalString("config")), path)
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	rules, err := lp.LoadSoDRules(path)
+	lp.DieIf(errors.Wrapf(err, "Failed loading rules from %v", path))
+	return rules
+}
+
+// refuseSoDViolations exits unless --force is given when an operation would create violations.
+func refuseSoDViolations(context *cli.Context, violations []lp.SoDViolation) {
+	if len(violations) == 0 {
+		return
+	}
+	prefix := "error"
+	if context.Bool("force") {
+		prefix = "warning"
+	}
+	for _, v := range violations {
+		lp.Log(prefix, v.String())
+	}
+	if !context.Bool("force") {
+		lp.DieIf(errors.Errorf("%d separation-of-duties violation(s) would be created. Use --force to proceed anyway", len(violations)))
+	}
+}
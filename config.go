@@ -1,9 +1,11 @@
 package lpmgt
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 // LastPassConfig is config structure for LastPass
@@ -12,6 +14,85 @@ type LastPassConfig struct {
 	EndPoint  string `yaml:"end_point_url"`
 	Secret    string `yaml:"secret"` // API Key
 	TimeZone string  `yaml:"timezone,omitempty"`
+	// AllowedMFAFactors lists multifactor types accepted by the security policy.
+	// Empty means every factor is accepted.
+	AllowedMFAFactors []string `yaml:"allowed_mfa_factors,omitempty"`
+	// BatchSize is the number of users sent in a single batch request.
+	BatchSize int `yaml:"batch_size,omitempty"`
+	// Onboarding maps a department or role to a template applied to new users.
+	Onboarding map[string]OnboardingTemplate `yaml:"onboarding,omitempty"`
+	// DynamicGroups are groups whose members are computed by rules.
+	DynamicGroups []DynamicGroup `yaml:"dynamic_groups,omitempty"`
+	// GrantStateFile is a local file recording temporary grants.
+	GrantStateFile string `yaml:"grant_state_file,omitempty"`
+	// SoDRulesFile is a YAML file of separation-of-duties rules enforced on group membership.
+	SoDRulesFile string `yaml:"sod_rules_file,omitempty"`
+	// ProtectedUsers and members of ProtectedGroups are not deleted, nor their MFA or password reset.
+	ProtectedUsers  []string `yaml:"protected_users,omitempty"`
+	ProtectedGroups []string `yaml:"protected_groups,omitempty"`
+	// ReadOnly allows only commands which do not mutate the tenant.
+	ReadOnly bool `yaml:"read_only,omitempty"`
+	// JournalFile is a local file recording every mutating request.
+	JournalFile string `yaml:"journal_file,omitempty"`
+	// EventWindow is the length of a period fetched by a single reporting request such as "24h".
+	EventWindow string `yaml:"event_window,omitempty"`
+	// EventConcurrency is the maximum number of reporting requests in flight.
+	EventConcurrency int `yaml:"event_concurrency,omitempty"`
+	// Operators maps names of operators to Ed25519 public keys in base64 which verify change requests.
+	// Keep it where operators cannot edit it, or an operator could register a second key of their own.
+	Operators map[string]string `yaml:"operators,omitempty"`
+	// ProposalTTL is how long a change request can be approved such as "24h".
+	ProposalTTL string `yaml:"proposal_ttl,omitempty"`
+	// OperatorKeyFile is the private key of this operator. Default is ~/.lpmgt/operator.key
+	OperatorKeyFile string `yaml:"operator_key_file,omitempty"`
+	// SIEM configures CEF, LEEF and syslog output of events and their collector.
+	SIEM SIEMConfig `yaml:"siem,omitempty"`
+}
+
+// EventReportOptions returns options of fetching events configured by EventWindow and EventConcurrency.
+func (c *LastPassConfig) EventReportOptions() (EventReportOptions, error) {
+	opts := EventReportOptions{Window: DefaultEventWindow, Concurrency: DefaultEventConcurrency}
+	if c.EventWindow != "" {
+		window, err := time.ParseDuration(c.EventWindow)
+		if err != nil || window <= 0 {
+			return opts, fmt.Errorf("event_window has to be a positive duration such as 24h: %v", c.EventWindow)
+		}
+		opts.Window = window
+	}
+	if c.EventConcurrency > 0 {
+		opts.Concurrency = c.EventConcurrency
+	}
+	return opts, nil
+}
+
+// OnboardingTemplate is a set of groups and attributes given to new users of a department or role.
+type OnboardingTemplate struct {
+	Groups     []string          `yaml:"groups"`
+	Attributes map[string]string `yaml:"attributes,omitempty"`
+}
+
+// Apply returns a copy of `user` with groups and attributes of the template added.
+// Attributes already set on the user take precedence.
+func (t OnboardingTemplate) Apply(user User) User {
+	groups := append([]string{}, t.Groups...)
+	for _, g := range user.Groups {
+		if !contains(groups, g) {
+			groups = append(groups, g)
+		}
+	}
+	user.Groups = groups
+
+	if len(t.Attributes) != 0 {
+		attrs := make(map[string]string)
+		for k, v := range t.Attributes {
+			attrs[k] = v
+		}
+		for k, v := range user.Attributes {
+			attrs[k] = v
+		}
+		user.Attributes = attrs
+	}
+	return user
 }
 
 const (
@@ -39,6 +120,10 @@ func LoadConfig(configFile string) (*LastPassConfig, error) {
 		config.TimeZone = "UTC"
 	}
 
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+
 	return config, nil
 }
 
@@ -75,3 +160,16 @@ func LoadCompanyIDFromEnvOrConfig(configFile string) string {
 	}
 	return config.CompanyID
 }
+
+// LoadReadOnly returns whether read-only mode is enabled in config.
+// Error is returned if the config file is given but cannot be loaded, so that read-only mode never fails open.
+func LoadReadOnly(configFile string) (bool, error) {
+	if configFile == "" {
+		return false, nil
+	}
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		return false, fmt.Errorf("Failed loading config %v: %v", configFile, err)
+	}
+	return config.ReadOnly, nil
+}
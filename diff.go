@@ -0,0 +1,70 @@
+package lpmgt
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldChange is a difference of a field between two records.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%v: %v -> %v", c.Field, formatValue(c.Before), formatValue(c.After))
+}
+
+// DiffUsers returns changed fields from `before` to `after` by their JSON names.
+func DiffUsers(before, after User) ([]FieldChange, error) {
+	b, err := toJSONMap(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toJSONMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	for k := range b {
+		fields = append(fields, k)
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, f := range fields {
+		if !reflect.DeepEqual(b[f], a[f]) {
+			changes = append(changes, FieldChange{Field: f, Before: b[f], After: a[f]})
+		}
+	}
+	return changes, nil
+}
+
+func toJSONMap(v interface{}) (m map[string]interface{}, err error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &m)
+	return
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,115 @@
+package lpmgt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DynamicGroup is a group whose members are computed from user data by rules.
+// By default an user matching any rule is a member. With `match: all` every rule has to match.
+// Disabled users are not members unless `include_disabled: true`.
+type DynamicGroup struct {
+	Group           string            `yaml:"group"`
+	Match           string            `yaml:"match,omitempty"`
+	EmailDomains    []string          `yaml:"email_domains,omitempty"`
+	MemberOf        []string          `yaml:"member_of,omitempty"`
+	Attributes      map[string]string `yaml:"attributes,omitempty"`
+	IncludeDisabled bool              `yaml:"include_disabled,omitempty"`
+}
+
+// Validate checks the dynamic group has a name and at least a rule.
+func (g DynamicGroup) Validate() error {
+	if g.Group == "" {
+		return fmt.Errorf("Dynamic group needs a name")
+	}
+	if g.Match != "" && g.Match != "any" && g.Match != "all" {
+		return fmt.Errorf("Dynamic group %v: match has to be either 'any' or 'all'", g.Group)
+	}
+	if len(g.EmailDomains) == 0 && len(g.MemberOf) == 0 && len(g.Attributes) == 0 {
+		return fmt.Errorf("Dynamic group %v has no rules", g.Group)
+	}
+	if contains(g.MemberOf, g.Group) {
+		return fmt.Errorf("Dynamic group %v refers to itself in member_of", g.Group)
+	}
+	return nil
+}
+
+// Matches reports whether `u` should be a member of the dynamic group.
+func (g DynamicGroup) Matches(u User) bool {
+	if u.Disabled && !g.IncludeDisabled {
+		return false
+	}
+	results := []bool{}
+	if len(g.EmailDomains) != 0 {
+		matched := false
+		domain := strings.ToLower(u.UserName[strings.LastIndex(u.UserName, "@")+1:])
+		for _, d := range g.EmailDomains {
+			if strings.ToLower(strings.TrimPrefix(d, "@")) == domain {
+				matched = true
+			}
+		}
+		results = append(results, matched)
+	}
+	if len(g.MemberOf) != 0 {
+		matched := false
+		for _, group := range g.MemberOf {
+			if contains(u.Groups, group) {
+				matched = true
+			}
+		}
+		results = append(results, matched)
+	}
+	for k, v := range g.Attributes {
+		results = append(results, u.Attributes[k] == v)
+	}
+
+	all := g.Match == "all"
+	for _, r := range results {
+		if r != all {
+			return r
+		}
+	}
+	return all && len(results) != 0
+}
+
+// ValidateDynamicGroups checks each dynamic group, and that no group is defined twice,
+// which would add and delete the same members.
+func ValidateDynamicGroups(groups []DynamicGroup) error {
+	defined := map[string]bool{}
+	for _, g := range groups {
+		if err := g.Validate(); err != nil {
+			return err
+		}
+		if defined[g.Group] {
+			return fmt.Errorf("Dynamic group %v is defined more than once. Combine its rules into one definition", g.Group)
+		}
+		defined[g.Group] = true
+	}
+	return nil
+}
+
+// PlanDynamicGroupSync returns changes adding users matching rules to dynamic groups
+// and deleting users who no longer match.
+func PlanDynamicGroupSync(users []User, groups []DynamicGroup) ([]TransferringUser, error) {
+	if err := ValidateDynamicGroups(groups); err != nil {
+		return nil, err
+	}
+
+	changes := []TransferringUser{}
+	for _, u := range sortedByUserName(users) {
+		change := TransferringUser{UserName: u.UserName}
+		for _, g := range groups {
+			isMember := contains(u.Groups, g.Group)
+			switch matches := g.Matches(u); {
+			case matches && !isMember:
+				change.Add = append(change.Add, g.Group)
+			case !matches && isMember:
+				change.Del = append(change.Del, g.Group)
+			}
+		}
+		if len(change.Add) != 0 || len(change.Del) != 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,222 @@
+package lpmgt
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EventMatch is a set of conditions on events. A kind of condition matches if any of its values matches.
+type EventMatch struct {
+	// Actions are exact actions, prefixes ending with `*` such as `Failed*`, or regular expressions in `/.../`.
+	Actions []string
+	// IPs are IP addresses or CIDR blocks such as 192.0.2.0/24.
+	IPs []string
+	// Usernames are patterns with `*` wildcards such as `*@example.com`.
+	Usernames []string
+	// Data are substrings of Data of events.
+	Data []string
+}
+
+// EventFilter selects events locally. An event is selected if it satisfies every kind of condition
+// to include, and none of conditions to exclude.
+type EventFilter struct {
+	include compiledEventMatch
+	exclude compiledEventMatch
+}
+
+type compiledEventMatch struct {
+	actions   []func(string) bool
+	networks  []*net.IPNet
+	usernames []string
+	data      []string
+}
+
+// NewEventFilter validates conditions and returns EventFilter.
+func NewEventFilter(include, exclude EventMatch) (*EventFilter, error) {
+	in, err := compileEventMatch(include)
+	if err != nil {
+		return nil, err
+	}
+	ex, err := compileEventMatch(exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &EventFilter{include: in, exclude: ex}, nil
+}
+
+// IsEmpty reports whether the filter selects every event.
+func (f *EventFilter) IsEmpty() bool {
+	return f == nil || f.include.isEmpty() && f.exclude.isEmpty()
+}
+
+// Matches reports whether `e` is selected. A nil filter selects every event.
+func (f *EventFilter) Matches(e Event) bool {
+	if f == nil {
+		return true
+	}
+	return f.include.matchesAll(e) && !f.exclude.matchesAny(e)
+}
+
+// Apply returns selected events.
+func (f *EventFilter) Apply(events []Event) []Event {
+	selected := []Event{}
+	for _, e := range events {
+		if f.Matches(e) {
+			selected = append(selected, e)
+		}
+	}
+	return selected
+}
+
+// Filter returns events selected by `f`.
+func (es *Events) Filter(f *EventFilter) *Events {
+	return &Events{Events: f.Apply(es.Events)}
+}
+
+func compileEventMatch(m EventMatch) (compiledEventMatch, error) {
+	c := compiledEventMatch{usernames: m.Usernames, data: m.Data}
+	for _, a := range m.Actions {
+		matcher, err := actionMatcher(a)
+		if err != nil {
+			return c, err
+		}
+		c.actions = append(c.actions, matcher)
+	}
+	for _, ip := range m.IPs {
+		cidr := ip
+		if !strings.Contains(ip, "/") {
+			cidr += "/128"
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				cidr = ip + "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return c, fmt.Errorf("Invalid IP address or CIDR %v", ip)
+		}
+		c.networks = append(c.networks, network)
+	}
+	for _, u := range m.Usernames {
+		if _, err := filepath.Match(u, ""); err != nil {
+			return c, fmt.Errorf("Invalid pattern %v: %v", u, err)
+		}
+	}
+	return c, nil
+}
+
+// actionMatcher returns a function matching actions by `pattern`.
+func actionMatcher(pattern string) (func(string) bool, error) {
+	switch {
+	case len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression %v: %v", pattern, err)
+		}
+		return re.MatchString, nil
+	case strings.HasSuffix(pattern, "*"):
+		prefix := strings.ToLower(strings.TrimSuffix(pattern, "*"))
+		return func(action string) bool { return strings.HasPrefix(strings.ToLower(action), prefix) }, nil
+	default:
+		return func(action string) bool { return strings.EqualFold(action, pattern) }, nil
+	}
+}
+
+func (c compiledEventMatch) isEmpty() bool {
+	return len(c.actions) == 0 && len(c.networks) == 0 && len(c.usernames) == 0 && len(c.data) == 0
+}
+
+func (c compiledEventMatch) matchesAll(e Event) bool {
+	return (len(c.actions) == 0 || c.matchesAction(e)) &&
+		(len(c.networks) == 0 || c.matchesIP(e)) &&
+		(len(c.usernames) == 0 || c.matchesUsername(e)) &&
+		(len(c.data) == 0 || c.matchesData(e))
+}
+
+func (c compiledEventMatch) matchesAny(e Event) bool {
+	return c.matchesAction(e) || c.matchesIP(e) || c.matchesUsername(e) || c.matchesData(e)
+}
+
+func (c compiledEventMatch) matchesAction(e Event) bool {
+	for _, m := range c.actions {
+		if m(e.Action) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compiledEventMatch) matchesIP(e Event) bool {
+	ip := net.ParseIP(e.IPAddress)
+	if ip == nil {
+		return false
+	}
+	for _, n := range c.networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compiledEventMatch) matchesUsername(e Event) bool {
+	for _, u := range c.usernames {
+		if matchesPattern(u, e.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compiledEventMatch) matchesData(e Event) bool {
+	for _, d := range c.data {
+		if strings.Contains(strings.ToLower(e.Data), strings.ToLower(d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// EventFields are columns of events selectable by ParseEventFields.
+var EventFields = []string{"time", "username", "ip", "action", "data", "id"}
+
+// ParseEventFields parses a comma-separated list of EventFields.
+func ParseEventFields(s string) ([]string, error) {
+	fields := []string{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !contains(EventFields, f) {
+			return nil, fmt.Errorf("Unknown field %v: choose from %v", f, strings.Join(EventFields, ", "))
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("No field is given")
+	}
+	return fields, nil
+}
+
+// Field returns the value of `field` of the event. Time is formatted in RFC3339 in `loc`.
+func (e Event) Field(field string, loc *time.Location) string {
+	switch field {
+	case "time":
+		return e.Time.In(loc).Format(time.RFC3339)
+	case "username":
+		return e.Username
+	case "ip":
+		return e.IPAddress
+	case "action":
+		return e.Action
+	case "data":
+		return e.Data
+	case "id":
+		return e.ID
+	}
+	return ""
+}
@@ -2,7 +2,9 @@ package lpmgt
 
 import (
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 	"encoding/json"
 	"github.com/pkg/errors"
@@ -139,7 +141,7 @@ func (s *EventService) GetEventReport(username, search string, from, to JSONLast
 		Search string                         `json:"search"`
 		User   string                         `json:"user"`
 		Format string                         `json:"format"`
-	}{User: username, From: from, To: to, Format: "siem"}
+	}{User: username, From: from, To: to, Search: search, Format: "siem"}
 
 	res, err := s.doRequest()
 	if err != nil {
@@ -156,21 +158,8 @@ func (s *EventService) GetEventReport(username, search string, from, to JSONLast
 }
 
 // GetAllEventReports fetches event of all users in certain period of time.
-// Filtering is also available by setting search string.
 func (s *EventService) GetAllEventReports(from, to JSONLastPassTime) (*Events, error) {
-	s.GetEventReport("allusers", "", from, to)
-	res, err := s.doRequest()
-	if err != nil {
-		return nil, err
-	}
-
-	var events Events
-	err = JSONBodyDecoder(res, &events)
-	if err != nil {
-		return nil, err
-	}
-
-	return &events, nil
+	return s.GetEventReport("allusers", "", from, to)
 }
 
 // GetAPIEventReports retrieves events triggered by API.
@@ -182,4 +171,232 @@ func (s *EventService) GetAPIEventReports(from, to JSONLastPassTime) (*Events, e
 		return nil, err
 	}
 	return events.GetUserEvents("API"), nil
-}
\ No newline at end of file
+}
+// UserEvents is events of a user.
+type UserEvents struct {
+	UserName string  `json:"username"`
+	Events   []Event `json:"events"`
+}
+
+// GetEventReportsOf fetches events of each of `usernames` in the order of `usernames`.
+// A few users are fetched by a request per user. Many users, or "API" which is not a valid user
+// to LastPass, are fetched by a single scan of all users filtered by username.
+func (s *EventService) GetEventReportsOf(usernames []string, search string, from, to JSONLastPassTime, opts EventReportOptions) ([]UserEvents, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultEventConcurrency
+	}
+	results := make([]UserEvents, len(usernames))
+	index := make(map[string]int)
+	scan := len(usernames) > opts.Concurrency
+	for i, name := range usernames {
+		results[i] = UserEvents{UserName: name, Events: []Event{}}
+		index[strings.ToLower(name)] = i
+		if strings.EqualFold(name, "api") {
+			scan = true
+		}
+	}
+
+	if scan {
+		it := s.IterateEventReports("allusers", search, from, to, opts)
+		defer it.Close()
+		for it.Next() {
+			e := it.Event()
+			if i, ok := index[strings.ToLower(e.Username)]; ok {
+				results[i].Events = append(results[i].Events, e)
+			}
+		}
+		return results, it.Err()
+	}
+
+	// Users are fetched concurrently, so windows of each user are fetched one by one.
+	perUser := opts
+	perUser.Concurrency = 1
+	errs := make([]error, len(usernames))
+	var wg sync.WaitGroup
+	for i, name := range usernames {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			events, err := s.IterateEventReports(name, search, from, to, perUser).Collect()
+			if err != nil {
+				errs[i] = errors.Wrapf(err, "Failed fetching events of %v", name)
+				return
+			}
+			results[i].Events = events.Events
+		}(i, name)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// Default options of EventIterator.
+const (
+	DefaultEventWindow      = 24 * time.Hour
+	DefaultEventConcurrency = 4
+)
+
+// EventReportOptions controls how a long period is fetched.
+type EventReportOptions struct {
+	// Window is the length of a period fetched by a single request.
+	Window time.Duration
+	// Concurrency is the maximum number of requests in flight.
+	Concurrency int
+}
+
+// EventIterator iterates events fetched window by window in time order without holding all of them.
+// Events with the same ID returned by adjacent windows are yielded only once.
+//
+//	it := s.IterateEventReports("allusers", "", from, to, opts)
+//	defer it.Close()
+//	for it.Next() {
+//		e := it.Event()
+//	}
+//	err := it.Err()
+type EventIterator struct {
+	windows <-chan eventWindow
+	done    chan struct{}
+	once    sync.Once
+	current []Event
+	event   Event
+	seen    map[string]bool
+	err     error
+}
+
+type eventWindow struct {
+	events []Event
+	err    error
+}
+
+// IterateEventReports splits the period from `from` to `to` into windows of opts.Window and fetches
+// them by at most opts.Concurrency requests at once. Windows are yielded in time order.
+func (s *EventService) IterateEventReports(username, search string, from, to JSONLastPassTime, opts EventReportOptions) *EventIterator {
+	window := opts.Window
+	if window <= 0 {
+		window = DefaultEventWindow
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultEventConcurrency
+	}
+
+	windows := make(chan eventWindow)
+	it := &EventIterator{windows: windows, done: make(chan struct{}), seen: make(map[string]bool)}
+
+	// Each window has its own channel so that they are yielded in order while fetched concurrently.
+	// A window waited for by the second goroutine and the buffered ones are in flight at most.
+	pending := make(chan chan eventWindow, concurrency-1)
+	go func() {
+		defer close(pending)
+		for start := from.JSONTime; start.Before(to.JSONTime); start = start.Add(window) {
+			end := start.Add(window)
+			if end.After(to.JSONTime) {
+				end = to.JSONTime
+			}
+			result := make(chan eventWindow, 1)
+			select {
+			case pending <- result:
+			case <-it.done:
+				return
+			}
+			go func(start, end time.Time) {
+				// EventService is not safe for concurrent use.
+				events, err := NewEventService(s.client).GetEventReport(username, search,
+					NewJSONLastPassTime(start), NewJSONLastPassTime(end))
+				w := eventWindow{err: err}
+				if err == nil {
+					w.events = events.Events
+					sort.SliceStable(w.events, func(i, j int) bool { return w.events[i].Time.Before(w.events[j].Time) })
+				}
+				result <- w
+			}(start, end)
+		}
+	}()
+	go func() {
+		defer close(windows)
+		for result := range pending {
+			w := <-result
+			select {
+			case windows <- w:
+			case <-it.done:
+				return
+			}
+			if w.err != nil {
+				it.Close()
+				return
+			}
+		}
+	}()
+	return it
+}
+
+// Next advances to the next event. It returns false at the end or on error.
+func (it *EventIterator) Next() bool {
+	for it.err == nil {
+		for len(it.current) != 0 {
+			e := it.current[0]
+			it.current = it.current[1:]
+			if key := e.key(); !it.seen[key] {
+				it.seen[key] = true
+				it.event = e
+				return true
+			}
+		}
+		w, ok := <-it.windows
+		if !ok {
+			return false
+		}
+		if w.err != nil {
+			it.err = w.err
+			return false
+		}
+		// Duplicates appear only in adjacent windows. Only keys of the previous window
+		// which appear again are kept so that memory does not grow with the period.
+		seen := make(map[string]bool)
+		for _, e := range w.events {
+			if k := e.key(); it.seen[k] {
+				seen[k] = true
+			}
+		}
+		it.seen = seen
+		it.current = w.events
+	}
+	return false
+}
+
+// Event returns the current event.
+func (it *EventIterator) Event() Event {
+	return it.event
+}
+
+// Err returns the error which stopped the iteration.
+func (it *EventIterator) Err() error {
+	return it.err
+}
+
+// Close stops fetching windows not yet requested. It has to be called unless all events are consumed.
+func (it *EventIterator) Close() {
+	it.once.Do(func() { close(it.done) })
+}
+
+// Collect returns all remaining events.
+func (it *EventIterator) Collect() (*Events, error) {
+	defer it.Close()
+	events := []Event{}
+	for it.Next() {
+		events = append(events, it.Event())
+	}
+	return &Events{Events: events}, it.Err()
+}
+
+// key identifies the event. Events without ID are identified by their content.
+func (e Event) key() string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return e.Time.UTC().Format(time.RFC3339Nano) + "\x00" + e.Username + "\x00" + e.IPAddress + "\x00" + e.Action + "\x00" + e.Data
+}
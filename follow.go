@@ -0,0 +1,149 @@
+package lpmgt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultFollowOverlap is how far back each poll of EventFollower reaches before the last seen event.
+const DefaultFollowOverlap = 10 * time.Minute
+
+// FollowState is the position of EventFollower kept between polls and runs.
+type FollowState struct {
+	// LastTime and LastID are of the latest event seen so far.
+	LastTime time.Time `json:"last_time"`
+	LastID   string    `json:"last_id,omitempty"`
+	// PolledTo is the end of the last successful poll, which moves on even while no event arrives.
+	PolledTo time.Time `json:"polled_to,omitempty"`
+	// Seen holds keys of events within the overlap before LastTime, which polls return again.
+	Seen map[string]time.Time `json:"seen,omitempty"`
+	path string
+}
+
+// DefaultFollowStateFile returns ~/.lpmgt/follow.json
+func DefaultFollowStateFile() string {
+	return filepath.Join(stateDir(), "follow.json")
+}
+
+// LoadFollowState reads the state file. An empty state is returned if it does not exist.
+func LoadFollowState(path string) (*FollowState, error) {
+	s := &FollowState{Seen: make(map[string]time.Time), path: path}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if s.Seen == nil {
+		s.Seen = make(map[string]time.Time)
+	}
+	return s, nil
+}
+
+// Save writes the state to its file.
+func (s *FollowState) Save() error {
+	b, err := IndentedJSON(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path, b)
+}
+
+// EventFollower polls events and returns only ones not returned before.
+//
+// Each poll starts Overlap before the latest event seen or the end of the last poll, whichever is later,
+// so that events which LastPass records late are not missed, and events seen before are dropped by their IDs.
+// Overlap also covers skew between the local clock and LastPass, which the end of a poll is taken from.
+type EventFollower struct {
+	Service *EventService
+	Search  string
+	Filter  *EventFilter
+	Overlap time.Duration
+	Options EventReportOptions
+	State   *FollowState
+	// Start is where the first poll starts when State is empty.
+	Start time.Time
+	// Now returns the current time. time.Now is used if it's nil.
+	Now func() time.Time
+}
+
+// Poll fetches events since the last poll and returns new ones selected by Filter in time order.
+// State is updated but not saved, so that callers save it after the events are delivered.
+func (f *EventFollower) Poll() ([]Event, error) {
+	now := time.Now()
+	if f.Now != nil {
+		now = f.Now()
+	}
+	overlap := f.Overlap
+	if overlap <= 0 {
+		overlap = DefaultFollowOverlap
+	}
+
+	// Polls start from whichever of the last event and the end of the last poll is later,
+	// so that a quiet period is not fetched again and again.
+	from := f.Start
+	if since := f.State.since(); !since.IsZero() {
+		from = since.Add(-overlap)
+	}
+	if from.IsZero() {
+		from = now.Add(-overlap)
+	}
+	if !from.Before(now) {
+		// The local clock is behind LastPass. Wait until it catches up.
+		return []Event{}, nil
+	}
+
+	// State is updated only after all events are fetched, so that a failed poll can be retried.
+	fetched, err := f.Service.IterateEventReports("allusers", f.Search, NewJSONLastPassTime(from), NewJSONLastPassTime(now), f.Options).Collect()
+	if err != nil {
+		return nil, err
+	}
+	events := []Event{}
+	for _, e := range fetched.Events {
+		key := e.key()
+		if _, ok := f.State.Seen[key]; ok {
+			continue
+		}
+		f.State.Seen[key] = e.Time
+		if !e.Time.Before(f.State.LastTime) {
+			f.State.LastTime = e.Time
+			f.State.LastID = e.ID
+		}
+		if f.Filter.Matches(e) {
+			events = append(events, e)
+		}
+	}
+
+	f.State.PolledTo = now
+	for key, t := range f.State.Seen {
+		if t.Before(f.State.since().Add(-overlap)) {
+			delete(f.State.Seen, key)
+		}
+	}
+	return events, nil
+}
+
+// Copy returns a copy of the state, which is put back when polled events fail to be delivered.
+func (s *FollowState) Copy() *FollowState {
+	c := *s
+	c.Seen = make(map[string]time.Time, len(s.Seen))
+	for k, t := range s.Seen {
+		c.Seen[k] = t
+	}
+	return &c
+}
+
+// since returns the later of LastTime and PolledTo.
+func (s *FollowState) since() time.Time {
+	if s.PolledTo.After(s.LastTime) {
+		return s.PolledTo
+	}
+	return s.LastTime
+}
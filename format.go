@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -22,9 +23,15 @@ type JSONLastPassTime struct {
 	JSONTime time.Time
 }
 
-// Format returns a textual representation of the time value formatted in LastPass Format
+// NewJSONLastPassTime returns JSONLastPassTime of `t` in LastPassTimeZone.
+func NewJSONLastPassTime(t time.Time) JSONLastPassTime {
+	return JSONLastPassTime{JSONTime: t.In(lastPassLocation())}
+}
+
+// Format returns a textual representation of the time value formatted in LastPass Format.
+// The time is converted into LastPassTimeZone first.
 func (j JSONLastPassTime) Format() string {
-	return j.JSONTime.Format(LastPassFormat)
+	return j.JSONTime.In(lastPassLocation()).Format(LastPassFormat)
 }
 
 // MarshalJSON encodes golang structure into json format
@@ -32,6 +39,38 @@ func (j JSONLastPassTime) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + j.Format() + `"`), nil
 }
 
+// UnmarshalJSON decodes time in LastPass Format in LastPassTimeZone.
+func (j *JSONLastPassTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	t, err := time.ParseInLocation(LastPassFormat, s, lastPassLocation())
+	if err != nil {
+		return err
+	}
+	j.JSONTime = t
+	return nil
+}
+
+var (
+	lastPassLoc     *time.Location
+	lastPassLocOnce sync.Once
+)
+
+// lastPassLocation returns location of LastPassTimeZone.
+// Falls back to a fixed UTC-5 zone when the timezone database is unavailable.
+func lastPassLocation() *time.Location {
+	lastPassLocOnce.Do(func() {
+		loc, err := time.LoadLocation(LastPassTimeZone)
+		if err != nil {
+			loc = time.FixedZone("EST", -5*60*60)
+		}
+		lastPassLoc = loc
+	})
+	return lastPassLoc
+}
+
 // JSONBodyDecoder reads the next JSON-encoded value from its
 // input and stores it in the value pointed to by out.
 func JSONBodyDecoder(resp *http.Response, out interface{}) error {
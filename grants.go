@@ -0,0 +1,148 @@
+package lpmgt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Grant is a temporary membership of a group which expires at ExpiresAt.
+type Grant struct {
+	UserName  string    `json:"username"`
+	Group     string    `json:"group"`
+	GrantedAt time.Time `json:"granted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	GrantedBy string    `json:"granted_by"`
+}
+
+// IsExpired reports whether the grant has expired at `now`.
+func (g Grant) IsExpired(now time.Time) bool {
+	return !now.Before(g.ExpiresAt)
+}
+
+func (g Grant) sameAs(o Grant) bool {
+	return strings.EqualFold(g.UserName, o.UserName) && g.Group == o.Group
+}
+
+// GrantStore is a local state file holding temporary grants.
+type GrantStore struct {
+	path   string
+	Grants []Grant `json:"grants"`
+}
+
+// DefaultGrantStateFile returns ~/.lpmgt/grants.json
+func DefaultGrantStateFile() string {
+	return filepath.Join(stateDir(), "grants.json")
+}
+
+// LoadGrantStore loads grants from `path`. A store without grants is returned if the file does not exist.
+func LoadGrantStore(path string) (*GrantStore, error) {
+	s := &GrantStore{path: path, Grants: []Grant{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes grants to the state file atomically.
+func (s *GrantStore) Save() error {
+	sort.Slice(s.Grants, func(i, j int) bool { return s.Grants[i].ExpiresAt.Before(s.Grants[j].ExpiresAt) })
+	b, err := IndentedJSON(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path, b)
+}
+
+// Put adds `g`, or extends the expiry of the same grant if it exists.
+func (s *GrantStore) Put(g Grant) {
+	for i, existing := range s.Grants {
+		if existing.sameAs(g) {
+			s.Grants[i].ExpiresAt = g.ExpiresAt
+			return
+		}
+	}
+	s.Grants = append(s.Grants, g)
+}
+
+// Find returns the grant of `username` to `group`.
+func (s *GrantStore) Find(username, group string) (Grant, bool) {
+	for _, g := range s.Grants {
+		if g.sameAs(Grant{UserName: username, Group: group}) {
+			return g, true
+		}
+	}
+	return Grant{}, false
+}
+
+// Expired returns grants which have expired at `now`.
+func (s *GrantStore) Expired(now time.Time) []Grant {
+	grants := []Grant{}
+	for _, g := range s.Grants {
+		if g.IsExpired(now) {
+			grants = append(grants, g)
+		}
+	}
+	return grants
+}
+
+// Remove deletes `g` from the store.
+func (s *GrantStore) Remove(g Grant) {
+	grants := []Grant{}
+	for _, existing := range s.Grants {
+		if !existing.sameAs(g) {
+			grants = append(grants, existing)
+		}
+	}
+	s.Grants = grants
+}
+
+// OperatorName returns the name of OS user running lpmgt.
+func OperatorName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// stateDir returns a directory where lpmgt keeps local state.
+func stateDir() string {
+	home := os.Getenv("HOME")
+	if u, err := user.Current(); err == nil {
+		home = u.HomeDir
+	}
+	return filepath.Join(home, ".lpmgt")
+}
+
+// writeFileAtomically writes to a temporary file first so that a crash never leaves a broken file.
+func writeFileAtomically(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
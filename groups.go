@@ -0,0 +1,167 @@
+package lpmgt
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Statuses of group members.
+const (
+	StatusActive        = "active"
+	StatusDisabled      = "disabled"
+	StatusNeverLoggedIn = "never logged in"
+)
+
+// GroupSummary is a summary of members of a group.
+// LastPass has no API for groups, so groups are derived from membership of users.
+type GroupSummary struct {
+	Name            string  `json:"name"`
+	NumberOfMembers int     `json:"number_of_members"`
+	MFAEnabled      int     `json:"mfa_enabled"`
+	MFAPercentage   float64 `json:"mfa_percentage"`
+	Disabled        int     `json:"disabled"`
+}
+
+// GroupMember is a member of a group with status and access.
+type GroupMember struct {
+	UserName      string   `json:"username"`
+	Status        string   `json:"status"`
+	IsAdmin       bool     `json:"admin"`
+	Multifactor   string   `json:"multifactor"`
+	LastLogin     string   `json:"last_login"`
+	SharedFolders []string `json:"shared_folders"`
+}
+
+// GroupDetail is a group with its members.
+type GroupDetail struct {
+	GroupSummary
+	Members []GroupMember `json:"members"`
+}
+
+// SummarizeGroups returns summaries of all groups in alphabetical order.
+func SummarizeGroups(users []User) []GroupSummary {
+	summaries := []GroupSummary{}
+	for name, members := range GroupMembers(users) {
+		summaries = append(summaries, summarizeGroup(name, members))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries
+}
+
+// DescribeGroup returns members of group `name` with their status and shared folders.
+func DescribeGroup(name string, users []User, folders []SharedFolder) (*GroupDetail, error) {
+	members, ok := GroupMembers(users)[name]
+	if !ok {
+		return nil, fmt.Errorf("Group %v does not exist", name)
+	}
+
+	detail := &GroupDetail{GroupSummary: summarizeGroup(name, members), Members: []GroupMember{}}
+	for _, u := range members {
+		m := GroupMember{
+			UserName:      u.UserName,
+			Status:        StatusActive,
+			IsAdmin:       u.IsAdmin,
+			Multifactor:   u.Multifactor,
+			LastLogin:     u.LastLogin,
+			SharedFolders: []string{},
+		}
+		switch {
+		case u.Disabled:
+			m.Status = StatusDisabled
+		case u.NeverLoggedIn:
+			m.Status = StatusNeverLoggedIn
+		}
+		for _, f := range FilterSharedFoldersByUser(folders, u.UserName) {
+			m.SharedFolders = append(m.SharedFolders, f.ShareFolderName)
+		}
+		sort.Strings(m.SharedFolders)
+		detail.Members = append(detail.Members, m)
+	}
+	sort.Slice(detail.Members, func(i, j int) bool { return detail.Members[i].UserName < detail.Members[j].UserName })
+	return detail, nil
+}
+
+func summarizeGroup(name string, members []User) GroupSummary {
+	s := GroupSummary{Name: name, NumberOfMembers: len(members)}
+	for _, u := range members {
+		if u.Multifactor != "" {
+			s.MFAEnabled++
+		}
+		if u.Disabled {
+			s.Disabled++
+		}
+	}
+	s.MFAPercentage = percentage(s.MFAEnabled, s.NumberOfMembers)
+	return s
+}
+
+// PlanGroupRename returns changes moving all members of group `from` to group `to`.
+func PlanGroupRename(users []User, from, to string) ([]TransferringUser, error) {
+	return PlanGroupMerge(users, []string{from}, to)
+}
+
+// PlanGroupMerge returns changes moving all members of `sources` into group `into`.
+// `into` may be one of `sources`, in which case its members are kept.
+func PlanGroupMerge(users []User, sources []string, into string) ([]TransferringUser, error) {
+	groups := GroupMembers(users)
+	for _, g := range sources {
+		if _, ok := groups[g]; !ok {
+			return nil, fmt.Errorf("Group %v does not exist", g)
+		}
+	}
+
+	changes := []TransferringUser{}
+	for _, u := range sortedByUserName(users) {
+		change := TransferringUser{UserName: u.UserName}
+		for _, g := range sources {
+			if g != into && contains(u.Groups, g) {
+				change.Del = append(change.Del, g)
+			}
+		}
+		if len(change.Del) == 0 {
+			continue
+		}
+		if !contains(u.Groups, into) {
+			change.Add = []string{into}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// PlanGroupSplit returns changes moving members of group `from` to groups given by `mapping`,
+// which maps username to a new group. Every member has to be mapped.
+func PlanGroupSplit(users []User, from string, mapping map[string]string) ([]TransferringUser, error) {
+	members, ok := GroupMembers(users)[from]
+	if !ok {
+		return nil, fmt.Errorf("Group %v does not exist", from)
+	}
+
+	changes := []TransferringUser{}
+	unmapped := []string{}
+	for _, u := range sortedByUserName(members) {
+		to, ok := mapping[u.UserName]
+		if !ok {
+			unmapped = append(unmapped, u.UserName)
+			continue
+		}
+		if to == from {
+			continue
+		}
+		change := TransferringUser{UserName: u.UserName, Del: []string{from}}
+		if !contains(u.Groups, to) {
+			change.Add = []string{to}
+		}
+		changes = append(changes, change)
+	}
+	if len(unmapped) != 0 {
+		return nil, fmt.Errorf("Members of %v are not mapped to any group: %v", from, unmapped)
+	}
+	return changes, nil
+}
+
+func sortedByUserName(users []User) []User {
+	sorted := append([]User{}, users...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UserName < sorted[j].UserName })
+	return sorted
+}
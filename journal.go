@@ -0,0 +1,355 @@
+package lpmgt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalID identifies a journal entry by the time it was recorded and a random suffix,
+// so that concurrent lpmgt processes never give the same ID without reading the journal.
+type JournalID string
+
+// NewJournalID returns a JournalID for an entry recorded at `t`.
+func NewJournalID(t time.Time) JournalID {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return JournalID(t.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix))
+}
+
+// UnmarshalJSON also accepts sequential numbers given by older versions of lpmgt.
+func (id *JournalID) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err == nil {
+		*id = JournalID(n.String())
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*id = JournalID(s)
+	return nil
+}
+
+// JournalEntry is a record of a mutating request sent to LastPass.
+type JournalEntry struct {
+	ID          JournalID       `json:"id"`
+	Time        time.Time       `json:"time"`
+	OSUser      string          `json:"os_user"`
+	Profile     string          `json:"profile,omitempty"`
+	CommandLine string          `json:"command_line"`
+	Command     string          `json:"command"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	PriorState  []User          `json:"prior_state"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	// Status is `status` of the response such as OK, WARN or FAIL.
+	Status string    `json:"status,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	Undoes JournalID `json:"undoes,omitempty"`
+	// Requester and Approver are set to entries sent by approving a change request.
+	Requester string `json:"requester,omitempty"`
+	Approver  string `json:"approver,omitempty"`
+}
+
+// Journal is an append-only local file of JournalEntry in JSON Lines.
+type Journal struct {
+	path        string
+	profile     string
+	commandLine string
+	// Undoes is recorded in entries to link them to the entry being undone.
+	Undoes JournalID
+	// Requester and Approver are recorded in entries sent by approving a change request.
+	Requester string
+	Approver  string
+	mu        sync.Mutex
+}
+
+// DefaultJournalFile returns ~/.lpmgt/journal.jsonl
+func DefaultJournalFile() string {
+	return filepath.Join(stateDir(), "journal.jsonl")
+}
+
+// NewJournal returns Journal which appends to `path`.
+// `profile` is the config file in use and `commandLine` is the lpmgt command being run.
+func NewJournal(path, profile, commandLine string) *Journal {
+	return &Journal{path: path, profile: profile, commandLine: commandLine}
+}
+
+// Entries returns all entries in the journal in the order they were recorded.
+func (j *Journal) Entries() ([]JournalEntry, error) {
+	entries := []JournalEntry{}
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%v: line %d: %v", j.path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Find returns the entry with `id`.
+func (j *Journal) Find(id JournalID) (JournalEntry, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return JournalEntry{}, fmt.Errorf("Journal entry %v does not exist", id)
+}
+
+// append writes `e` at the end of the journal under a file lock, so that entries written by
+// concurrent lpmgt processes never interleave.
+func (j *Journal) append(e JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e.ID = NewJournalID(e.Time)
+	e.OSUser = OperatorName()
+	e.Profile = j.profile
+	e.CommandLine = j.commandLine
+	e.Undoes = j.Undoes
+	e.Requester = j.Requester
+	e.Approver = j.Approver
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// record appends the request and its result to the journal.
+// The response body is read and replaced so that callers can still decode it.
+func (j *Journal) record(command string, payload interface{}, prior []User, res *http.Response, reqErr error) error {
+	e := JournalEntry{Time: time.Now(), Command: command, PriorState: prior}
+	if b, err := json.Marshal(payload); err == nil {
+		e.Payload = b
+	}
+	if reqErr != nil {
+		e.Error = reqErr.Error()
+	}
+	if res != nil && res.Body != nil {
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if json.Valid(body) {
+			e.Result = body
+			if status, _, err := decodeStatus(body); err == nil {
+				e.Status = status
+			}
+		} else {
+			quoted, _ := json.Marshal(string(body))
+			e.Result = quoted
+		}
+	}
+	return j.append(e)
+}
+
+// usernamesInPayload returns usernames of users a request acts on.
+func usernamesInPayload(payload interface{}) []string {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(b, &objects); err != nil {
+		var object map[string]interface{}
+		if err := json.Unmarshal(b, &object); err != nil {
+			return nil
+		}
+		objects = append(objects, object)
+	}
+
+	names := []string{}
+	for _, o := range objects {
+		if name, ok := o["username"].(string); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// snapshot fetches current records of users which `payload` acts on.
+// Users who do not exist are omitted.
+func (c *LastPassClient) snapshot(payload interface{}) ([]User, error) {
+	names := usernamesInPayload(payload)
+	prior := []User{}
+	if len(names) == 0 {
+		return prior, nil
+	}
+
+	var data interface{} = User{}
+	if len(names) == 1 {
+		data = User{UserName: names[0]}
+	}
+	res, err := c.DoRequest("getuserdata", data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var us users
+	// LastPass returns {"Users":[]} when the user does not exist, which fails to decode.
+	if err := json.Unmarshal(body, &us); err != nil {
+		return prior, nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[strings.ToLower(name)] = true
+	}
+	for _, u := range us.getUsers() {
+		if wanted[strings.ToLower(u.UserName)] {
+			prior = append(prior, u)
+		}
+	}
+	return prior, nil
+}
+
+// applied returns error unless LastPass answered the request of the entry with status OK and no error.
+// Status is taken from Result for entries recorded before Status was added.
+func (e JournalEntry) applied() error {
+	if e.Error != "" {
+		return fmt.Errorf("Journal entry %v failed and has nothing to undo", e.ID)
+	}
+	status, messages, err := decodeStatus(e.Result)
+	if err != nil {
+		return fmt.Errorf("Journal entry %v has no status of the response and cannot be undone safely", e.ID)
+	}
+	if e.Status != "" {
+		status = e.Status
+	}
+	if status != "OK" {
+		return fmt.Errorf("LastPass answered journal entry %v with status %v, so it may not have been applied", e.ID, status)
+	}
+	if len(messages) != 0 {
+		return fmt.Errorf("Journal entry %v was partially applied: %v. Reverse the applied changes manually", e.ID, strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// UndoJournalEntry sends requests reversing `e`.
+// Supported are batchchangegrp, batchadd of users who existed before and deactivation by deluser.
+// Only entries which LastPass answered with status OK are undone.
+func UndoJournalEntry(c *LastPassClient, e JournalEntry) error {
+	if err := e.applied(); err != nil {
+		return err
+	}
+	s := NewUserService(c)
+	if c.Journal != nil {
+		c.Journal.Undoes = e.ID
+		defer func() { c.Journal.Undoes = "" }()
+	}
+
+	switch e.Command {
+	case "batchchangegrp":
+		var changes []TransferringUser
+		if err := json.Unmarshal(e.Payload, &changes); err != nil {
+			return err
+		}
+		reversed := []TransferringUser{}
+		for _, t := range changes {
+			reversed = append(reversed, t.Reverse())
+		}
+		_, err := s.ChangeGroupsMembership(reversed)
+		return err
+	case "batchadd":
+		var added []User
+		if err := json.Unmarshal(e.Payload, &added); err != nil {
+			var single User
+			if err := json.Unmarshal(e.Payload, &single); err != nil {
+				return err
+			}
+			added = []User{single}
+		}
+		prior := make(map[string]User)
+		for _, u := range e.PriorState {
+			prior[strings.ToLower(u.UserName)] = u
+		}
+		restoring := []User{}
+		created := []string{}
+		for _, u := range added {
+			p, ok := prior[strings.ToLower(u.UserName)]
+			if !ok {
+				created = append(created, u.UserName)
+				continue
+			}
+			if p.Attributes == nil {
+				// Remove attributes added by the entry from a user who had none.
+				p.Attributes = map[string]string{}
+			}
+			restoring = append(restoring, p)
+		}
+		if len(created) != 0 {
+			return fmt.Errorf("Creation of %v cannot be undone. Use `delete user` instead", strings.Join(created, ", "))
+		}
+		for _, u := range restoring {
+			if err := s.UpdateUser(u); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "deluser":
+		deleted := struct {
+			UserName     string `json:"username"`
+			DeleteAction int    `json:"deleteaction"`
+		}{}
+		if err := json.Unmarshal(e.Payload, &deleted); err != nil {
+			return err
+		}
+		if DeactivationMode(deleted.DeleteAction) != Deactivate {
+			return fmt.Errorf("Only deactivation can be undone, but %v was removed or deleted", deleted.UserName)
+		}
+		return s.ReactivateUser(deleted.UserName)
+	default:
+		return fmt.Errorf("%v cannot be undone", e.Command)
+	}
+}
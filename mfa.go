@@ -0,0 +1,116 @@
+package lpmgt
+
+import (
+	"sort"
+)
+
+// NoGroup is a pseudo group name for users who do not belong to any group.
+const NoGroup = "(no group)"
+
+// MFACoverage summarizes multifactor adoption among users of a group.
+type MFACoverage struct {
+	Group      string         `json:"group"`
+	Total      int            `json:"total"`
+	Enabled    int            `json:"enabled"`
+	Percentage float64        `json:"percentage"`
+	Factors    map[string]int `json:"factors"`
+}
+
+// FactorPercentage returns the ratio of users in the group using `factor`.
+func (c *MFACoverage) FactorPercentage(factor string) float64 {
+	return percentage(c.Factors[factor], c.Total)
+}
+
+func (c *MFACoverage) add(u User) {
+	c.Total++
+	if u.Multifactor != "" {
+		c.Enabled++
+		c.Factors[u.Multifactor]++
+	}
+	c.Percentage = percentage(c.Enabled, c.Total)
+}
+
+// DisallowedFactorUser is a user whose multifactor is not in the allowed list.
+type DisallowedFactorUser struct {
+	UserName string `json:"username"`
+	Factor   string `json:"factor"`
+}
+
+// MFAReport is a breakdown of multifactor adoption by factor type and group.
+type MFAReport struct {
+	AdminsWithoutMFA  []string               `json:"admins_without_mfa"`
+	DisallowedFactors []DisallowedFactorUser `json:"disallowed_factors"`
+	Overall           MFACoverage            `json:"overall"`
+	Groups            []MFACoverage          `json:"groups"`
+}
+
+// NewMFAReport builds MFAReport from users.
+// Disabled users are excluded since they cannot log in.
+// If allowedFactors is empty, no factor is reported as disallowed.
+func NewMFAReport(users []User, allowedFactors []string) *MFAReport {
+	allowed := make(map[string]bool)
+	for _, f := range allowedFactors {
+		allowed[f] = true
+	}
+
+	report := &MFAReport{
+		AdminsWithoutMFA:  []string{},
+		DisallowedFactors: []DisallowedFactorUser{},
+		Overall:           MFACoverage{Group: "overall", Factors: make(map[string]int)},
+		Groups:            []MFACoverage{},
+	}
+	groups := make(map[string]*MFACoverage)
+	for _, u := range users {
+		if u.Disabled {
+			continue
+		}
+		if u.IsAdmin && u.Multifactor == "" {
+			report.AdminsWithoutMFA = append(report.AdminsWithoutMFA, u.UserName)
+		}
+		if u.Multifactor != "" && len(allowed) != 0 && !allowed[u.Multifactor] {
+			report.DisallowedFactors = append(report.DisallowedFactors,
+				DisallowedFactorUser{UserName: u.UserName, Factor: u.Multifactor})
+		}
+
+		report.Overall.add(u)
+		belongings := u.Groups
+		if len(belongings) == 0 {
+			belongings = []string{NoGroup}
+		}
+		for _, g := range belongings {
+			if _, ok := groups[g]; !ok {
+				groups[g] = &MFACoverage{Group: g, Factors: make(map[string]int)}
+			}
+			groups[g].add(u)
+		}
+	}
+
+	for _, c := range groups {
+		report.Groups = append(report.Groups, *c)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Group < report.Groups[j].Group
+	})
+	sort.Strings(report.AdminsWithoutMFA)
+	sort.Slice(report.DisallowedFactors, func(i, j int) bool {
+		return report.DisallowedFactors[i].UserName < report.DisallowedFactors[j].UserName
+	})
+	return report
+}
+
+// SortedFactors returns factor names used in the group in alphabetical order.
+func (c *MFACoverage) SortedFactors() []string {
+	factors := []string{}
+	for f := range c.Factors {
+		factors = append(factors, f)
+	}
+	sort.Strings(factors)
+	return factors
+}
+
+func percentage(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,110 @@
+package lpmgt
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Flags derived from user data which need attention.
+const (
+	FlagAdminWithoutMFA = "admin without MFA"
+	FlagNoMFA           = "no MFA"
+	FlagStaleLogin      = "stale login"
+	FlagNeverLoggedIn   = "never logged in"
+	FlagDisabled        = "disabled"
+	FlagFolderAdmin     = "administers shared folders"
+)
+
+// FolderPermission is a permission of an user on a shared folder.
+type FolderPermission struct {
+	SharedFolderName string `json:"sharedfoldername"`
+	ReadOnly         bool   `json:"readonly"`
+	Give             bool   `json:"give"`
+	CanAdminister    bool   `json:"can_administer"`
+}
+
+// UserProfile combines the user record with access and activity of the user.
+type UserProfile struct {
+	User          User               `json:"user"`
+	LastLogin     *time.Time         `json:"last_login,omitempty"`
+	SharedFolders []FolderPermission `json:"shared_folders"`
+	Events        []Event            `json:"events"`
+	Flags         []string           `json:"flags"`
+}
+
+// NewUserProfile builds UserProfile of `user`.
+// Login before `now` - `staleAfter` is flagged as FlagStaleLogin.
+func NewUserProfile(user User, folders []SharedFolder, events []Event, now time.Time, staleAfter time.Duration) *UserProfile {
+	p := &UserProfile{
+		User:          user,
+		SharedFolders: []FolderPermission{},
+		Events:        []Event{},
+		Flags:         []string{},
+	}
+
+	for _, f := range FilterSharedFoldersByUser(folders, user.UserName) {
+		for _, u := range f.Users {
+			if !strings.EqualFold(u.UserName, user.UserName) {
+				continue
+			}
+			p.SharedFolders = append(p.SharedFolders, FolderPermission{
+				SharedFolderName: f.ShareFolderName,
+				ReadOnly:         isTruthy(u.Readonly),
+				Give:             isTruthy(u.Give),
+				CanAdminister:    isTruthy(u.CanAdminister),
+			})
+		}
+	}
+	sort.Slice(p.SharedFolders, func(i, j int) bool {
+		return p.SharedFolders[i].SharedFolderName < p.SharedFolders[j].SharedFolderName
+	})
+
+	for _, e := range events {
+		if strings.EqualFold(e.Username, user.UserName) {
+			p.Events = append(p.Events, e)
+		}
+	}
+	sort.Slice(p.Events, func(i, j int) bool { return p.Events[i].Time.Before(p.Events[j].Time) })
+
+	if t, err := ParseLastPassTime(user.LastLogin); err == nil {
+		p.LastLogin = &t
+	}
+
+	switch {
+	case user.IsAdmin && user.Multifactor == "":
+		p.Flags = append(p.Flags, FlagAdminWithoutMFA)
+	case user.Multifactor == "":
+		p.Flags = append(p.Flags, FlagNoMFA)
+	}
+	switch {
+	case user.NeverLoggedIn:
+		p.Flags = append(p.Flags, FlagNeverLoggedIn)
+	case p.LastLogin != nil && p.LastLogin.Before(now.Add(-staleAfter)):
+		p.Flags = append(p.Flags, FlagStaleLogin)
+	}
+	if user.Disabled {
+		p.Flags = append(p.Flags, FlagDisabled)
+	}
+	for _, f := range p.SharedFolders {
+		if f.CanAdminister {
+			p.Flags = append(p.Flags, FlagFolderAdmin)
+			break
+		}
+	}
+	return p
+}
+
+// ParseLastPassTime parses time in LastPassFormat and LastPassTimeZone returned by LastPass.
+func ParseLastPassTime(v string) (time.Time, error) {
+	loc, err := time.LoadLocation(LastPassTimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(LastPassFormat, v, loc)
+}
+
+// isTruthy interprets permission values of shared folders, which come as "0"/"1" or "true"/"false".
+func isTruthy(v string) bool {
+	return v == "1" || v == "true"
+}
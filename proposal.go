@@ -0,0 +1,532 @@
+package lpmgt
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProposedCall is a mutating request planned in a change request.
+type ProposedCall struct {
+	Command    string          `json:"command"`
+	Payload    json.RawMessage `json:"payload"`
+	PriorState []User          `json:"prior_state"`
+}
+
+// Proposal is a change request which a different operator has to approve before it is sent.
+// Signature is made by the requester's operator key over all the other fields,
+// so that neither the requester nor the calls can be altered after proposing.
+type Proposal struct {
+	// ID is random and recorded when the change request is executed, so that it's never executed twice.
+	ID string `json:"id"`
+	// CompanyID is the tenant which the change request is made for.
+	CompanyID    string         `json:"company_id"`
+	Requester    string         `json:"requester"`
+	RequestedAt  time.Time      `json:"requested_at"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+	Profile      string         `json:"profile,omitempty"`
+	CommandLine  string         `json:"command_line"`
+	Calls        []ProposedCall `json:"calls"`
+	PreStateHash string         `json:"pre_state_hash"`
+	Signature    string         `json:"signature"`
+	mu           sync.Mutex
+}
+
+// DefaultProposalTTL is how long a change request can be approved after it's proposed.
+const DefaultProposalTTL = 24 * time.Hour
+
+// NewProposal returns an empty Proposal for `companyID` which expires after `ttl`.
+// Requester is set when it's signed.
+func NewProposal(profile, commandLine, companyID string, ttl time.Duration) *Proposal {
+	if ttl <= 0 {
+		ttl = DefaultProposalTTL
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	now := time.Now()
+	return &Proposal{
+		ID:          hex.EncodeToString(id),
+		CompanyID:   companyID,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(ttl),
+		Profile:     profile,
+		CommandLine: commandLine,
+		Calls:       []ProposedCall{},
+	}
+}
+
+// LoadProposal reads a change request file.
+func LoadProposal(path string) (*Proposal, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proposal{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Save writes the change request to `path`.
+func (p *Proposal) Save(path string) error {
+	b, err := IndentedJSON(p)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// signedContent returns bytes which Signature is made over.
+func (p *Proposal) signedContent() ([]byte, error) {
+	return json.Marshal(struct {
+		ID           string         `json:"id"`
+		CompanyID    string         `json:"company_id"`
+		Requester    string         `json:"requester"`
+		RequestedAt  time.Time      `json:"requested_at"`
+		ExpiresAt    time.Time      `json:"expires_at"`
+		Profile      string         `json:"profile,omitempty"`
+		CommandLine  string         `json:"command_line"`
+		Calls        []ProposedCall `json:"calls"`
+		PreStateHash string         `json:"pre_state_hash"`
+	}{p.ID, p.CompanyID, p.Requester, p.RequestedAt, p.ExpiresAt, p.Profile, p.CommandLine, p.Calls, p.PreStateHash})
+}
+
+// Sign sets `requester` and signs the change request by the requester's operator key.
+func (p *Proposal) Sign(requester string, key ed25519.PrivateKey) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Requester = requester
+	b, err := p.signedContent()
+	if err != nil {
+		return err
+	}
+	p.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, b))
+	return nil
+}
+
+// DefaultOperatorKeyFile returns ~/.lpmgt/operator.key
+func DefaultOperatorKeyFile() string {
+	return filepath.Join(stateDir(), "operator.key")
+}
+
+// GenerateOperatorKey writes a new Ed25519 private key to `path` and returns its public key in base64.
+// An existing key is never overwritten.
+func GenerateOperatorKey(path string) (string, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, base64.StdEncoding.EncodeToString(private)); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(public), nil
+}
+
+// LoadOperatorKey reads a private key written by GenerateOperatorKey.
+func LoadOperatorKey(path string) (ed25519.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%v is not an operator key", path)
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// operatorPublicKey returns the public key of `name` registered in `operators`.
+func operatorPublicKey(operators map[string]string, name string) (ed25519.PublicKey, error) {
+	for n, encoded := range operators {
+		if !strings.EqualFold(n, name) {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Public key of operator %v is invalid", n)
+		}
+		return ed25519.PublicKey(key), nil
+	}
+	return nil, fmt.Errorf("Operator %v is not registered in operators in config", name)
+}
+
+// IdentifyOperator returns the name under which the public key of `key` is registered in `operators`.
+func IdentifyOperator(operators map[string]string, key ed25519.PrivateKey) (string, error) {
+	public := key.Public().(ed25519.PublicKey)
+	names := []string{}
+	for name := range operators {
+		if registered, err := operatorPublicKey(operators, name); err == nil && registered.Equal(public) {
+			names = append(names, name)
+		}
+	}
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("Public key %v is not registered in operators in config", base64.StdEncoding.EncodeToString(public))
+	case 1:
+		return names[0], nil
+	default:
+		sort.Strings(names)
+		return "", fmt.Errorf("Public key is registered for more than one operator: %v", strings.Join(names, ", "))
+	}
+}
+
+func (p *Proposal) add(command string, payload interface{}, prior []User) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Calls = append(p.Calls, ProposedCall{Command: command, Payload: b, PriorState: prior})
+
+	states := [][]User{}
+	for _, c := range p.Calls {
+		states = append(states, c.PriorState)
+	}
+	p.PreStateHash, err = StateHash(states)
+	return err
+}
+
+// StateHash returns SHA-256 of fields of users which mutating commands act on.
+// Fields changing without operators such as last login are excluded.
+func StateHash(states [][]User) (string, error) {
+	type state struct {
+		UserName    string            `json:"username"`
+		FullName    string            `json:"fullname"`
+		Disabled    bool              `json:"disabled"`
+		IsAdmin     bool              `json:"admin"`
+		Multifactor string            `json:"multifactor"`
+		Groups      []string          `json:"groups"`
+		Attributes  map[string]string `json:"attribs"`
+	}
+
+	normalized := [][]state{}
+	for _, users := range states {
+		ss := []state{}
+		for _, u := range users {
+			groups := append([]string{}, u.Groups...)
+			sort.Strings(groups)
+			ss = append(ss, state{
+				UserName:    strings.ToLower(u.UserName),
+				FullName:    u.FullName,
+				Disabled:    u.Disabled,
+				IsAdmin:     u.IsAdmin,
+				Multifactor: u.Multifactor,
+				Groups:      groups,
+				Attributes:  u.Attributes,
+			})
+		}
+		sort.Slice(ss, func(i, j int) bool { return ss[i].UserName < ss[j].UserName })
+		normalized = append(normalized, ss)
+	}
+
+	b, err := json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// acceptedResponse returns a response as if LastPass accepted the request.
+func acceptedResponse() *http.Response {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"status":"OK"}`)),
+	}
+}
+
+// CallResult is an outcome of a proposed call executed on approval.
+type CallResult struct {
+	Command string                   `json:"command"`
+	Status  *APIResultStatusForBatch `json:"status,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// VerifyProposal verifies `p` and returns the name of the approver holding `key`.
+// The change request has to be signed by the requester registered in `operators`, the approver has to
+// be a different operator, and users the calls act on must be in the same state as observed when the
+// change was proposed.
+func VerifyProposal(c *LastPassClient, p *Proposal, operators map[string]string, key ed25519.PrivateKey) (string, error) {
+	requesterKey, err := operatorPublicKey(operators, p.Requester)
+	if err != nil {
+		return "", err
+	}
+	signature, err := base64.StdEncoding.DecodeString(p.Signature)
+	if err != nil {
+		return "", fmt.Errorf("Signature of the change request is invalid")
+	}
+	content, err := p.signedContent()
+	if err != nil {
+		return "", err
+	}
+	if !ed25519.Verify(requesterKey, content, signature) {
+		return "", fmt.Errorf("Signature does not match. The change request was altered or not signed by %v", p.Requester)
+	}
+	if p.ID == "" {
+		return "", fmt.Errorf("The change request has no ID. Propose it again")
+	}
+	if p.CompanyID != c.CompanyID {
+		return "", fmt.Errorf("The change request was proposed for company %v, not %v", p.CompanyID, c.CompanyID)
+	}
+	if !time.Now().Before(p.ExpiresAt) {
+		return "", fmt.Errorf("The change request expired at %v. Propose it again", p.ExpiresAt.Format(time.RFC3339))
+	}
+	if executed, err := isProposalExecuted(DefaultExecutedProposalsFile(), p.ID); err != nil || executed {
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("The change request %v has already been executed", p.ID)
+	}
+
+	approver, err := IdentifyOperator(operators, key)
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(approver, p.Requester) || requesterKey.Equal(key.Public()) {
+		return "", fmt.Errorf("%v proposed this change and cannot approve it", approver)
+	}
+
+	recorded := [][]User{}
+	for _, call := range p.Calls {
+		recorded = append(recorded, call.PriorState)
+	}
+	if hash, err := StateHash(recorded); err != nil || hash != p.PreStateHash {
+		return "", fmt.Errorf("pre_state_hash does not match prior states in the change request")
+	}
+
+	states := [][]User{}
+	for _, call := range p.Calls {
+		prior, err := c.snapshot(call.Payload)
+		if err != nil {
+			return "", err
+		}
+		states = append(states, prior)
+	}
+	hash, err := StateHash(states)
+	if err != nil {
+		return "", err
+	}
+	if hash != p.PreStateHash {
+		return "", fmt.Errorf("Users have changed since the change was proposed. Propose it again")
+	}
+	return approver, nil
+}
+
+// Guard evaluates the calls against `protection`, `rules` and `admins` as the commands proposing them do.
+// It returns reasons for acting on protected users and groups and new separation-of-duties violations,
+// which can be overridden, and error if no enabled admin would remain, which cannot.
+// `rules` may be nil. Prior states of the calls are assumed to be verified by VerifyProposal.
+func (p *Proposal) Guard(protection Protection, rules *SoDRules, admins []User) ([]string, []SoDViolation, error) {
+	reasons := []string{}
+	violations := []SoDViolation{}
+	deleting := []string{}
+	for _, call := range p.Calls {
+		prior := make(map[string]User)
+		for _, u := range call.PriorState {
+			prior[strings.ToLower(u.UserName)] = u
+		}
+		changed := func(before, after User) {
+			if rules != nil {
+				violations = append(violations, rules.NewViolations(before, after)...)
+			}
+		}
+
+		switch call.Command {
+		case "deluser", "disablemultifactor", "resetpassword":
+			target := struct {
+				UserName string `json:"username"`
+			}{}
+			if err := json.Unmarshal(call.Payload, &target); err != nil {
+				return nil, nil, fmt.Errorf("%v: %v", call.Command, err)
+			}
+			if u, ok := prior[strings.ToLower(target.UserName)]; ok {
+				if reason := protection.Reason(u); reason != "" {
+					reasons = append(reasons, reason)
+				}
+			}
+			if call.Command == "deluser" {
+				deleting = append(deleting, target.UserName)
+			}
+		case "batchchangegrp":
+			var changes []TransferringUser
+			if err := json.Unmarshal(call.Payload, &changes); err != nil {
+				return nil, nil, fmt.Errorf("%v: %v", call.Command, err)
+			}
+			for _, t := range changes {
+				if groups := protection.ProtectedGroupsIn(t.Del); len(groups) != 0 {
+					reasons = append(reasons, fmt.Sprintf("%v would leave protected group %v", t.UserName, strings.Join(groups, ", ")))
+				}
+				before, ok := prior[strings.ToLower(t.UserName)]
+				if !ok {
+					continue
+				}
+				after := before
+				after.Groups = []string{}
+				for _, g := range before.Groups {
+					if !contains(t.Del, g) {
+						after.Groups = append(after.Groups, g)
+					}
+				}
+				after.Groups = append(after.Groups, t.Add...)
+				changed(before, after)
+			}
+		case "batchadd":
+			var added []User
+			if err := json.Unmarshal(call.Payload, &added); err != nil {
+				var single User
+				if err := json.Unmarshal(call.Payload, &single); err != nil {
+					return nil, nil, fmt.Errorf("%v: %v", call.Command, err)
+				}
+				added = []User{single}
+			}
+			for _, u := range added {
+				before, ok := prior[strings.ToLower(u.UserName)]
+				if !ok {
+					changed(User{UserName: u.UserName}, u)
+					continue
+				}
+				if u.Groups == nil {
+					continue
+				}
+				leaving := []string{}
+				for _, g := range before.Groups {
+					if !contains(u.Groups, g) {
+						leaving = append(leaving, g)
+					}
+				}
+				if groups := protection.ProtectedGroupsIn(leaving); len(groups) != 0 {
+					reasons = append(reasons, fmt.Sprintf("%v would leave protected group %v", u.UserName, strings.Join(groups, ", ")))
+				}
+				after := before
+				after.Groups = u.Groups
+				changed(before, after)
+			}
+		}
+	}
+	return reasons, violations, CheckRemainingAdmins(admins, deleting)
+}
+
+// ExecuteProposal sends calls of `p` verified by VerifyProposal.
+// The requester and `approver` are recorded in journal entries of the calls.
+// The ID of `p` is recorded before sending, so that it's never executed again even if a call fails.
+func ExecuteProposal(c *LastPassClient, p *Proposal, approver string) ([]CallResult, error) {
+	if err := markProposalExecuted(DefaultExecutedProposalsFile(), p.ID, approver); err != nil {
+		return nil, err
+	}
+	if c.Journal != nil {
+		c.Journal.Requester, c.Journal.Approver = p.Requester, approver
+		defer func() { c.Journal.Requester, c.Journal.Approver = "", "" }()
+	}
+
+	results := []CallResult{}
+	for _, call := range p.Calls {
+		result := CallResult{Command: call.Command}
+		res, err := c.DoRequest(call.Command, call.Payload)
+		if err == nil {
+			status := &APIResultStatusForBatch{}
+			if err = JSONBodyDecoder(res, status); err == nil {
+				result.Status = status
+				err = status.Error()
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			return results, fmt.Errorf("%v failed: %v", call.Command, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// DefaultExecutedProposalsFile returns ~/.lpmgt/executed_proposals.jsonl
+func DefaultExecutedProposalsFile() string {
+	return filepath.Join(stateDir(), "executed_proposals.jsonl")
+}
+
+// executedProposal is a line of the file recording executed change requests.
+type executedProposal struct {
+	ID         string    `json:"id"`
+	ExecutedAt time.Time `json:"executed_at"`
+	Approver   string    `json:"approver"`
+}
+
+// isProposalExecuted reports whether `id` is recorded in `path`.
+func isProposalExecuted(path, id string) (bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return findExecutedProposal(f, id)
+}
+
+func findExecutedProposal(f *os.File, id string) (bool, error) {
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e executedProposal
+		if err := json.Unmarshal(scanner.Bytes(), &e); err == nil && e.ID == id {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// markProposalExecuted records `id` in `path` under a file lock, or returns error if it's already recorded,
+// so that concurrent approvals on this machine execute a change request only once.
+func markProposalExecuted(path, id, approver string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	executed, err := findExecutedProposal(f, id)
+	if err != nil {
+		return err
+	}
+	if executed {
+		return fmt.Errorf("The change request %v has already been executed", id)
+	}
+	b, err := json.Marshal(executedProposal{ID: id, ExecutedAt: time.Now(), Approver: approver})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,61 @@
+package lpmgt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Protection is a list of users and groups which destructive operations refuse to act on.
+type Protection struct {
+	Users  []string
+	Groups []string
+}
+
+// NewProtection returns Protection configured in `config`.
+func NewProtection(config *LastPassConfig) Protection {
+	return Protection{Users: config.ProtectedUsers, Groups: config.ProtectedGroups}
+}
+
+// Reason returns why `u` is protected, or "" if it's not.
+func (p Protection) Reason(u User) string {
+	for _, name := range p.Users {
+		if strings.EqualFold(name, u.UserName) {
+			return fmt.Sprintf("%v is a protected user", u.UserName)
+		}
+	}
+	if groups := p.ProtectedGroupsIn(u.Groups); len(groups) != 0 {
+		return fmt.Sprintf("%v belongs to protected group %v", u.UserName, strings.Join(groups, ", "))
+	}
+	return ""
+}
+
+// ProtectedGroupsIn returns protected groups among `groups`.
+func (p Protection) ProtectedGroupsIn(groups []string) []string {
+	protected := []string{}
+	for _, g := range groups {
+		if contains(p.Groups, g) {
+			protected = append(protected, g)
+		}
+	}
+	return protected
+}
+
+// CheckLastAdmin returns error if `username` is the only enabled admin among `admins`.
+func CheckLastAdmin(admins []User, username string) error {
+	isAdmin := false
+	others := 0
+	for _, a := range admins {
+		if a.Disabled {
+			continue
+		}
+		if strings.EqualFold(a.UserName, username) {
+			isAdmin = true
+			continue
+		}
+		others++
+	}
+	if isAdmin && others == 0 {
+		return fmt.Errorf("%v is the last remaining admin", username)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package lpmgt
 
 import (
 	"net/http"
+	"strings"
 )
 
 // SharedFolder is a LastPass Object in which users share accounts.
@@ -76,6 +77,29 @@ func (s *FolderService) GetSharedFolders() ([]SharedFolder, error) {
 	return sf, nil
 }
 
+// GetSharedFoldersOf returns shared folders which `username` has access to.
+func (s *FolderService) GetSharedFoldersOf(username string) ([]SharedFolder, error) {
+	folders, err := s.GetSharedFolders()
+	if err != nil {
+		return nil, err
+	}
+	return FilterSharedFoldersByUser(folders, username), nil
+}
+
+// FilterSharedFoldersByUser returns folders which `username` is a member of.
+func FilterSharedFoldersByUser(folders []SharedFolder, username string) []SharedFolder {
+	sf := []SharedFolder{}
+	for _, folder := range folders {
+		for _, u := range folder.Users {
+			if strings.EqualFold(u.UserName, username) {
+				sf = append(sf, folder)
+				break
+			}
+		}
+	}
+	return sf
+}
+
 func (s *FolderService) doRequest() (*http.Response, error) {
 	res, err := s.client.DoRequest(s.command, s.data)
 	if err != nil {
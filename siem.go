@@ -0,0 +1,218 @@
+package lpmgt
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventOutputFormat is a format which SIEM products ingest.
+type EventOutputFormat string
+
+const (
+	// OutputCEF is ArcSight Common Event Format.
+	OutputCEF EventOutputFormat = "cef"
+	// OutputLEEF is IBM QRadar Log Event Extended Format 1.0.
+	OutputLEEF EventOutputFormat = "leef"
+	// OutputSyslog is RFC 5424 syslog with event fields in structured data.
+	OutputSyslog EventOutputFormat = "syslog"
+)
+
+const (
+	// DefaultEventSeverity is severity of actions which no SeverityRule matches.
+	DefaultEventSeverity = 3
+	// DefaultSyslogFacility is authpriv.
+	DefaultSyslogFacility = 10
+	// DefaultSyslogSDID is SD-ID of structured data holding event fields.
+	// 32473 is the enterprise number reserved for documentation by RFC 5612.
+	DefaultSyslogSDID = "lastpass@32473"
+)
+
+// SeverityRule gives Severity from 0 (lowest) to 10 (highest) to events of Action.
+// Action is exact, a prefix ending with `*` or a regular expression in `/.../` as in EventMatch.
+type SeverityRule struct {
+	Action   string `yaml:"action"`
+	Severity int    `yaml:"severity"`
+}
+
+// SIEMConfig configures formatting and forwarding of events to a SIEM.
+type SIEMConfig struct {
+	// Address of a syslog collector such as udp://host:514, tcp://host:601 or tls://host:6514.
+	Address string `yaml:"address,omitempty"`
+	// CAFile verifies the collector over TLS instead of system root certificates.
+	CAFile   string `yaml:"ca_file,omitempty"`
+	Facility *int   `yaml:"facility,omitempty"`
+	SDID     string `yaml:"sd_id,omitempty"`
+	// DefaultSeverity is given to actions which none of Severities matches.
+	DefaultSeverity *int `yaml:"default_severity,omitempty"`
+	// Severities are evaluated in order and the first matching rule is used.
+	Severities []SeverityRule `yaml:"severities,omitempty"`
+}
+
+// EventFormatter turns events into lines of a SIEM format.
+type EventFormatter struct {
+	Format          EventOutputFormat
+	ProductVersion  string
+	Hostname        string
+	Facility        int
+	SDID            string
+	defaultSeverity int
+	severities      []compiledSeverityRule
+}
+
+type compiledSeverityRule struct {
+	matches  func(string) bool
+	severity int
+}
+
+// NewEventFormatter returns EventFormatter of `format` configured by `config`.
+func NewEventFormatter(format EventOutputFormat, config SIEMConfig, productVersion string) (*EventFormatter, error) {
+	switch format {
+	case OutputCEF, OutputLEEF, OutputSyslog:
+	default:
+		return nil, fmt.Errorf("Unknown output format %v: choose from cef, leef or syslog", format)
+	}
+	f := &EventFormatter{
+		Format:          format,
+		ProductVersion:  productVersion,
+		Hostname:        "-",
+		Facility:        DefaultSyslogFacility,
+		SDID:            DefaultSyslogSDID,
+		defaultSeverity: DefaultEventSeverity,
+	}
+	if h, err := os.Hostname(); err == nil && h != "" {
+		f.Hostname = h
+	}
+	if config.Facility != nil {
+		if *config.Facility < 0 || *config.Facility > 23 {
+			return nil, fmt.Errorf("facility has to be from 0 to 23: %d", *config.Facility)
+		}
+		f.Facility = *config.Facility
+	}
+	if config.SDID != "" {
+		f.SDID = config.SDID
+	}
+	if config.DefaultSeverity != nil {
+		if *config.DefaultSeverity < 0 || *config.DefaultSeverity > 10 {
+			return nil, fmt.Errorf("default_severity has to be from 0 to 10: %d", *config.DefaultSeverity)
+		}
+		f.defaultSeverity = *config.DefaultSeverity
+	}
+	for _, r := range config.Severities {
+		if r.Severity < 0 || r.Severity > 10 {
+			return nil, fmt.Errorf("severity of %v has to be from 0 to 10: %d", r.Action, r.Severity)
+		}
+		matches, err := actionMatcher(r.Action)
+		if err != nil {
+			return nil, err
+		}
+		f.severities = append(f.severities, compiledSeverityRule{matches: matches, severity: r.Severity})
+	}
+	return f, nil
+}
+
+// Severity returns severity from 0 to 10 of the event by the first matching SeverityRule.
+func (f *EventFormatter) Severity(e Event) int {
+	for _, r := range f.severities {
+		if r.matches(e.Action) {
+			return r.severity
+		}
+	}
+	return f.defaultSeverity
+}
+
+// FormatEvent returns the event as a line in Format without a trailing newline.
+func (f *EventFormatter) FormatEvent(e Event) string {
+	switch f.Format {
+	case OutputCEF:
+		return f.cef(e)
+	case OutputLEEF:
+		return f.leef(e)
+	default:
+		return f.syslog(e, f.structuredData(e), e.Action+" "+e.Data)
+	}
+}
+
+// SyslogMessage returns the event as an RFC 5424 message to be sent to a collector.
+// CEF and LEEF lines are carried in MSG.
+func (f *EventFormatter) SyslogMessage(e Event) string {
+	if f.Format == OutputSyslog {
+		return f.FormatEvent(e)
+	}
+	return f.syslog(e, "-", f.FormatEvent(e))
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefValueEscaper    = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	sdParamEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`, "\n", " ", "\r", " ")
+	syslogMsgEscaper    = strings.NewReplacer("\n", " ", "\r", " ")
+)
+
+func (f *EventFormatter) cef(e Event) string {
+	header := []string{"CEF:0", "LastPass", "lpmgt", f.ProductVersion, e.Action, e.Action, strconv.Itoa(f.Severity(e))}
+	for i := 1; i < len(header); i++ {
+		header[i] = cefHeaderEscaper.Replace(header[i])
+	}
+	extension := []string{fmt.Sprintf("rt=%d", e.Time.UnixNano()/int64(time.Millisecond))}
+	for _, kv := range [][2]string{{"suser", e.Username}, {"src", e.IPAddress}, {"msg", e.Data}, {"externalId", e.ID}} {
+		if kv[1] != "" {
+			extension = append(extension, kv[0]+"="+cefExtensionEscaper.Replace(kv[1]))
+		}
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(extension, " ")
+}
+
+func (f *EventFormatter) leef(e Event) string {
+	header := []string{"LEEF:1.0", "LastPass", "lpmgt", f.ProductVersion, e.Action}
+	for i := 1; i < len(header); i++ {
+		header[i] = cefHeaderEscaper.Replace(header[i])
+	}
+	attributes := []string{
+		"devTime=" + e.Time.UTC().Format("Jan 02 2006 15:04:05"),
+		"devTimeFormat=MMM dd yyyy HH:mm:ss",
+		"sev=" + strconv.Itoa(f.Severity(e)),
+	}
+	for _, kv := range [][2]string{{"usrName", e.Username}, {"src", e.IPAddress}, {"msg", e.Data}, {"externalId", e.ID}} {
+		if kv[1] != "" {
+			attributes = append(attributes, kv[0]+"="+leefValueEscaper.Replace(kv[1]))
+		}
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(attributes, "\t")
+}
+
+func (f *EventFormatter) structuredData(e Event) string {
+	params := []string{}
+	for _, kv := range [][2]string{{"username", e.Username}, {"ip", e.IPAddress}, {"action", e.Action}, {"data", e.Data}, {"id", e.ID}} {
+		if kv[1] != "" {
+			params = append(params, fmt.Sprintf(`%v="%v"`, kv[0], sdParamEscaper.Replace(kv[1])))
+		}
+	}
+	return "[" + strings.Join(append([]string{f.SDID}, params...), " ") + "]"
+}
+
+// syslog returns an RFC 5424 message of the event with `sd` as STRUCTURED-DATA and `msg` as MSG.
+func (f *EventFormatter) syslog(e Event, sd, msg string) string {
+	pri := f.Facility*8 + syslogSeverity(f.Severity(e))
+	timestamp := e.Time.UTC().Format("2006-01-02T15:04:05.000Z")
+	return fmt.Sprintf("<%d>1 %v %v lpmgt - - %v %v", pri, timestamp, f.Hostname, sd, syslogMsgEscaper.Replace(strings.TrimSpace(msg)))
+}
+
+// syslogSeverity maps severity from 0 to 10 into syslog severity from informational(6) to critical(2).
+func syslogSeverity(severity int) int {
+	switch {
+	case severity >= 9:
+		return 2
+	case severity >= 7:
+		return 3
+	case severity >= 5:
+		return 4
+	case severity >= 3:
+		return 5
+	default:
+		return 6
+	}
+}
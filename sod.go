@@ -0,0 +1,153 @@
+package lpmgt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SoDRule is a separation-of-duties rule on group membership.
+// Exactly one of the following has to be set.
+//   - Exclusive: a user must not belong to more than one of the groups.
+//   - AdminOnly: only admins may belong to the groups.
+//   - Group and Requires: members of Group must belong to at least one of Requires.
+type SoDRule struct {
+	Name      string   `yaml:"name"`
+	Exclusive []string `yaml:"exclusive,omitempty"`
+	AdminOnly []string `yaml:"admin_only,omitempty"`
+	Group     string   `yaml:"group,omitempty"`
+	Requires  []string `yaml:"requires,omitempty"`
+}
+
+// SoDRules is a set of separation-of-duties rules.
+type SoDRules struct {
+	Rules []SoDRule `yaml:"rules"`
+}
+
+// SoDViolation is a violation of a rule by an user.
+type SoDViolation struct {
+	Rule     string `json:"rule"`
+	UserName string `json:"username"`
+	Message  string `json:"message"`
+}
+
+func (v SoDViolation) String() string {
+	return fmt.Sprintf("[%v] %v: %v", v.Rule, v.UserName, v.Message)
+}
+
+// LoadSoDRules loads rules file in YAML format.
+func LoadSoDRules(path string) (*SoDRules, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := &SoDRules{}
+	if err := yaml.UnmarshalStrict(b, rules); err != nil {
+		return nil, err
+	}
+	for i, r := range rules.Rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%v: rule #%d: %v", path, i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r SoDRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	kinds := 0
+	if len(r.Exclusive) != 0 {
+		kinds++
+		if len(r.Exclusive) < 2 {
+			return fmt.Errorf("%v: exclusive needs at least 2 groups", r.Name)
+		}
+	}
+	if len(r.AdminOnly) != 0 {
+		kinds++
+	}
+	if r.Group != "" || len(r.Requires) != 0 {
+		kinds++
+		if r.Group == "" || len(r.Requires) == 0 {
+			return fmt.Errorf("%v: both group and requires are needed", r.Name)
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("%v: exactly one of exclusive, admin_only or group/requires has to be set", r.Name)
+	}
+	return nil
+}
+
+// Check returns violations of `u`.
+func (rs *SoDRules) Check(u User) []SoDViolation {
+	violations := []SoDViolation{}
+	for _, r := range rs.Rules {
+		switch {
+		case len(r.Exclusive) != 0:
+			belongings := []string{}
+			for _, g := range r.Exclusive {
+				if contains(u.Groups, g) {
+					belongings = append(belongings, g)
+				}
+			}
+			if len(belongings) > 1 {
+				violations = append(violations, SoDViolation{Rule: r.Name, UserName: u.UserName,
+					Message: fmt.Sprintf("belongs to mutually exclusive groups %v", strings.Join(belongings, ", "))})
+			}
+		case len(r.AdminOnly) != 0:
+			if u.IsAdmin {
+				continue
+			}
+			for _, g := range r.AdminOnly {
+				if contains(u.Groups, g) {
+					violations = append(violations, SoDViolation{Rule: r.Name, UserName: u.UserName,
+						Message: fmt.Sprintf("non-admin belongs to admin-only group %v", g)})
+				}
+			}
+		default:
+			if !contains(u.Groups, r.Group) {
+				continue
+			}
+			satisfied := false
+			for _, g := range r.Requires {
+				if contains(u.Groups, g) {
+					satisfied = true
+				}
+			}
+			if !satisfied {
+				violations = append(violations, SoDViolation{Rule: r.Name, UserName: u.UserName,
+					Message: fmt.Sprintf("belongs to %v without any of %v", r.Group, strings.Join(r.Requires, ", "))})
+			}
+		}
+	}
+	return violations
+}
+
+// Evaluate returns violations of all users ordered by username.
+func (rs *SoDRules) Evaluate(users []User) []SoDViolation {
+	violations := []SoDViolation{}
+	for _, u := range users {
+		violations = append(violations, rs.Check(u)...)
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].UserName < violations[j].UserName })
+	return violations
+}
+
+// NewViolations returns violations of `after` which `before` does not have.
+func (rs *SoDRules) NewViolations(before, after User) []SoDViolation {
+	existing := make(map[SoDViolation]bool)
+	for _, v := range rs.Check(before) {
+		existing[v] = true
+	}
+	violations := []SoDViolation{}
+	for _, v := range rs.Check(after) {
+		if !existing[v] {
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
@@ -1,6 +1,10 @@
 package lpmgt
 
 import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
 	"github.com/pkg/errors"
 )
 
@@ -23,21 +27,42 @@ func (s *APIResultStatus)IsOK() bool {
 	return s.Status == "OK"
 }
 
+// UnmarshalJSON accepts `error` either as a string or an array of strings.
+func (s *APIResultStatus) UnmarshalJSON(b []byte) error {
+	status, messages, err := decodeStatus(b)
+	if err != nil {
+		return err
+	}
+	s.Status = status
+	s.Errors = strings.Join(messages, "; ")
+	return nil
+}
+
 func (s *APIResultStatus) Error() error {
 	if s.IsOK() {
 		return nil
 	}
-	b, e := IndentedJSON(s.Errors)
-	if e != nil {
-		return e
+	if s.Errors == "" {
+		return errors.Errorf("LastPass returned status %v", s.Status)
 	}
-	return errors.New(string(b))
+	return errors.New(s.Errors)
 }
 
 func (s *APIResultStatus) String() string {
 	return s.Status
 }
 
+// UnmarshalJSON accepts `error` either as a string or an array of strings.
+func (s *APIResultStatusForPasswordResetting) UnmarshalJSON(b []byte) error {
+	status, messages, err := decodeStatus(b)
+	if err != nil {
+		return err
+	}
+	s.Status = status
+	s.Errors = messages
+	return nil
+}
+
 func (s *APIResultStatusForPasswordResetting) String() string {
 	return s.Status
 }
@@ -46,9 +71,100 @@ func (s *APIResultStatusForPasswordResetting) Error() error {
 	if s.Status == "OK" {
 		return nil
 	}
-	b, e := IndentedJSON(s.Errors)
-	if e != nil {
-		return e
+	if len(s.Errors) == 0 {
+		return errors.Errorf("LastPass returned status %v", s.Status)
+	}
+	return errors.New(strings.Join(s.Errors, "; "))
+}
+
+// APIResultStatusForBatch is returned by batch commands such as batchadd and batchchangegrp.
+// Messages are held in either `error` or `errors`, as a string or an array of strings.
+// {"status":"WARN","errors":["user2@lastpass.com does not exist"]}
+type APIResultStatusForBatch struct {
+	Status string   `json:"status,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// UnmarshalJSON accepts every known shape of error messages.
+func (s *APIResultStatusForBatch) UnmarshalJSON(b []byte) error {
+	status, messages, err := decodeStatus(b)
+	if err != nil {
+		return err
+	}
+	s.Status = status
+	s.Errors = messages
+	return nil
+}
+
+// decodeStatus returns status and error messages held in either `error` or `errors`,
+// as a string or an array of strings.
+func decodeStatus(b []byte) (string, []string, error) {
+	raw := struct {
+		Status string          `json:"status"`
+		Error  json.RawMessage `json:"error"`
+		Errors json.RawMessage `json:"errors"`
+	}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return "", nil, err
+	}
+	var all []string
+	for _, m := range []json.RawMessage{raw.Error, raw.Errors} {
+		if len(m) == 0 || string(m) == "null" {
+			continue
+		}
+		var messages []string
+		if err := json.Unmarshal(m, &messages); err == nil {
+			all = append(all, messages...)
+			continue
+		}
+		var message string
+		if err := json.Unmarshal(m, &message); err != nil {
+			return "", nil, errors.Wrapf(err, "Failed UnMarshalling error messages: %s", m)
+		}
+		all = append(all, message)
 	}
-	return errors.New(string(b))
-}
\ No newline at end of file
+	return raw.Status, all, nil
+}
+
+// IsOK checks status of response from LastPass
+func (s *APIResultStatusForBatch) IsOK() bool {
+	return s.Status == "OK"
+}
+
+// ErrorFor returns the message mentioning `username`, or "" if there is none.
+func (s *APIResultStatusForBatch) ErrorFor(username string) string {
+	for _, e := range s.Errors {
+		if mentionsUser(e, username) {
+			return e
+		}
+	}
+	return ""
+}
+
+// mentionsUser reports whether `message` contains `username` as a whole word,
+// so that a message about aa@example.com is not taken as one about a@example.com.
+func mentionsUser(message, username string) bool {
+	words := strings.FieldsFunc(message, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`"'`+"`"+`,;:()[]{}<>`, r)
+	})
+	for _, w := range words {
+		if strings.EqualFold(strings.TrimRight(w, "."), username) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *APIResultStatusForBatch) String() string {
+	return s.Status
+}
+
+func (s *APIResultStatusForBatch) Error() error {
+	if s.Status == "OK" || s.Status == "WARN" && len(s.Errors) == 0 {
+		return nil
+	}
+	if len(s.Errors) == 0 {
+		return errors.Errorf("LastPass returned status %v", s.Status)
+	}
+	return errors.New(strings.Join(s.Errors, "; "))
+}
@@ -0,0 +1,94 @@
+package lpmgt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"time"
+)
+
+// syslogDialTimeout bounds connecting to a collector.
+const syslogDialTimeout = 10 * time.Second
+
+// SyslogSender sends messages to a syslog collector over UDP, TCP or TLS.
+// Messages over TCP and TLS are framed by octet counting of RFC 6587 and RFC 5425.
+type SyslogSender struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	conn      net.Conn
+}
+
+// NewSyslogSender connects to `address` such as udp://host:514, tcp://host:601 or tls://host:6514.
+// Over TLS, the collector is verified by certificates in `caFile`, or by system root certificates if it's empty.
+func NewSyslogSender(address, caFile string) (*SyslogSender, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("Collector address has to be udp://host:port, tcp://host:port or tls://host:port: %v", address)
+	}
+	s := &SyslogSender{network: u.Scheme, address: u.Host}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "tls":
+		s.tlsConfig = &tls.Config{ServerName: u.Hostname()}
+		if caFile != "" {
+			pem, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("No certificate is found in %v", caFile)
+			}
+			s.tlsConfig.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("Unknown protocol %v: choose from udp, tcp or tls", u.Scheme)
+	}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SyslogSender) connect() (err error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	if s.tlsConfig != nil {
+		s.conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	} else {
+		s.conn, err = dialer.Dial(s.network, s.address)
+	}
+	return err
+}
+
+// Send sends a message. A broken TCP or TLS connection is reconnected once.
+// As with any syslog over TCP, a message written just before the collector closes the connection may be lost.
+func (s *SyslogSender) Send(message string) error {
+	frame := []byte(message)
+	if s.network != "udp" {
+		frame = []byte(fmt.Sprintf("%d %s", len(message), message))
+	}
+	if s.conn != nil {
+		if _, err := s.conn.Write(frame); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+// Close closes the connection.
+func (s *SyslogSender) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,102 @@
+package lpmgt
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are layouts of absolute times accepted by ParseTime besides RFC3339.
+var dateLayouts = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02T15:04:05"}
+
+var relativeTime = regexp.MustCompile(`^([+-])(\d+)([dw])$`)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// ParseTime parses `s` as one of
+//   - RFC3339 timestamp such as 2024-03-10T09:00:00+09:00
+//   - date and optionally time such as 2024-03-10 or 2024-03-10 09:00 in `loc`
+//   - duration relative to `now` such as -36h, -90m, -7d or -2w. Days and weeks are calendar days in `loc`
+//   - now, today, yesterday, or last-<weekday> such as last-monday meaning the start of the day in `loc`
+func ParseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	now = now.In(loc)
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch lower := strings.ToLower(s); {
+	case lower == "now":
+		return now, nil
+	case lower == "today":
+		return startOfToday, nil
+	case lower == "yesterday":
+		return startOfToday.AddDate(0, 0, -1), nil
+	case strings.HasPrefix(lower, "last-"):
+		weekday, ok := weekdays[strings.TrimPrefix(lower, "last-")]
+		if !ok {
+			return time.Time{}, fmt.Errorf("Unknown weekday in %v", s)
+		}
+		days := int(now.Weekday()-weekday+7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return startOfToday.AddDate(0, 0, -days), nil
+	}
+
+	if m := relativeTime.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		if m[3] == "w" {
+			n *= 7
+		}
+		if m[1] == "-" {
+			n = -n
+		}
+		return now.AddDate(0, 0, n), nil
+	}
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid relative time %v: %v", s, err)
+		}
+		return now.Add(d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Cannot parse time %v: use RFC3339, a date such as 2006-01-02, a relative time such as -36h or -7d, or today, yesterday or last-<weekday>", s)
+}
+
+// ParseTimeRange parses `from` and `to` by ParseTime into times in LastPassTimeZone.
+// Empty `to` means `now`, and empty `from` means `defaultDuration` before `to`.
+func ParseTimeRange(from, to string, now time.Time, loc *time.Location, defaultDuration time.Duration) (JSONLastPassTime, JSONLastPassTime, error) {
+	end := now
+	if to != "" {
+		t, err := ParseTime(to, now, loc)
+		if err != nil {
+			return JSONLastPassTime{}, JSONLastPassTime{}, err
+		}
+		end = t
+	}
+	start := end.Add(-defaultDuration)
+	if from != "" {
+		t, err := ParseTime(from, now, loc)
+		if err != nil {
+			return JSONLastPassTime{}, JSONLastPassTime{}, err
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return JSONLastPassTime{}, JSONLastPassTime{}, fmt.Errorf("Start of the range %v is not before its end %v", start.In(loc), end.In(loc))
+	}
+	return NewJSONLastPassTime(start), NewJSONLastPassTime(end), nil
+}
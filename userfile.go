@@ -0,0 +1,436 @@
+package lpmgt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UsersFileFormat is a format of a file listing users to be created.
+type UsersFileFormat string
+
+const (
+	// FormatAuto detects format from the extension or content of the file.
+	FormatAuto UsersFileFormat = ""
+	// FormatJSON is `{"data":[...]}` shape.
+	FormatJSON UsersFileFormat = "json"
+	// FormatJSONLines is one user object per line.
+	FormatJSONLines UsersFileFormat = "jsonl"
+	// FormatYAML is either a list of users or `data:` key holding the list.
+	FormatYAML UsersFileFormat = "yaml"
+	// FormatCSV has a header line. Groups are separated by `;`,
+	// and columns prefixed by `attr:` are custom attributes.
+	FormatCSV UsersFileFormat = "csv"
+	// FormatText is one username per line. Lines starting with `#` are comments.
+	FormatText UsersFileFormat = "txt"
+)
+
+// CSVAttributePrefix is a prefix of CSV columns mapped to custom attributes.
+const CSVAttributePrefix = "attr:"
+
+// UsersFileError is an error found in a specific line of users file.
+type UsersFileError struct {
+	Line    int
+	Message string
+}
+
+func (e UsersFileError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// UsersFileErrors is a list of errors found while validating users file.
+type UsersFileErrors []UsersFileError
+
+func (es UsersFileErrors) Error() string {
+	messages := []string{}
+	for _, e := range es {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// userRecord is a user entry in users file.
+type userRecord struct {
+	UserName   string            `json:"username" yaml:"username"`
+	FullName   string            `json:"fullname,omitempty" yaml:"fullname,omitempty"`
+	Groups     []string          `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Attributes map[string]string `json:"attribs,omitempty" yaml:"attribs,omitempty"`
+}
+
+type numberedRecord struct {
+	line   int
+	record userRecord
+}
+
+// LoadUsersFile reads users from file in `format` and validates the whole file.
+// All problems found are returned at once as UsersFileErrors.
+func LoadUsersFile(path string, format UsersFileFormat) ([]User, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatAuto {
+		format = DetectUsersFileFormat(path, b)
+	}
+
+	var records []numberedRecord
+	var errs UsersFileErrors
+	switch format {
+	case FormatJSON:
+		records, errs = parseUsersJSON(b)
+	case FormatJSONLines:
+		records, errs = parseUsersJSONLines(b)
+	case FormatYAML:
+		records, errs = parseUsersYAML(b)
+	case FormatCSV:
+		records, errs = parseUsersCSV(b)
+	case FormatText:
+		records, errs = parseUsersText(b)
+	default:
+		return nil, fmt.Errorf("Unknown users file format: %v", format)
+	}
+
+	errs = append(errs, validateUserRecords(records)...)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	users := []User{}
+	for _, r := range records {
+		users = append(users, User{
+			UserName:   r.record.UserName,
+			FullName:   r.record.FullName,
+			Groups:     r.record.Groups,
+			Attributes: r.record.Attributes,
+		})
+	}
+	return users, nil
+}
+
+// DetectUsersFileFormat guesses format from the extension, then from the content.
+func DetectUsersFileFormat(path string, content []byte) UsersFileFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".jsonl", ".ndjson":
+		return FormatJSONLines
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".csv":
+		return FormatCSV
+	case ".txt":
+		return FormatText
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	firstLine := trimmed
+	if i := bytes.IndexByte(trimmed, '\n'); i >= 0 {
+		firstLine = bytes.TrimSpace(trimmed[:i])
+	}
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")) && json.Valid(trimmed):
+		return FormatJSON
+	case bytes.HasPrefix(firstLine, []byte("{")):
+		return FormatJSONLines
+	case bytes.HasPrefix(firstLine, []byte("-")), bytes.Contains(firstLine, []byte(": ")), bytes.HasSuffix(firstLine, []byte(":")):
+		return FormatYAML
+	case bytes.Contains(firstLine, []byte("@")) && !bytes.ContainsAny(firstLine, ",;\t"):
+		return FormatText
+	default:
+		return FormatCSV
+	}
+}
+
+func parseUsersJSON(b []byte) (records []numberedRecord, errs UsersFileErrors) {
+	lines := newLineCounter(b)
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	// Walk tokens until the array held by "data" so that each user gets its line.
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, UsersFileErrors{{Line: 1, Message: `users file must be in {"data":[...]} shape`}}
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, UsersFileErrors{{Line: lines.at(dec.InputOffset()), Message: err.Error()}}
+		}
+		if key != "data" {
+			errs = append(errs, UsersFileError{Line: lines.at(dec.InputOffset()), Message: fmt.Sprintf("unknown key %v", key)})
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, append(errs, UsersFileError{Line: lines.at(dec.InputOffset()), Message: err.Error()})
+			}
+			continue
+		}
+		if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+			return nil, append(errs, UsersFileError{Line: lines.at(dec.InputOffset()), Message: `"data" must be an array`})
+		}
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, append(errs, UsersFileError{Line: lines.at(dec.InputOffset()), Message: err.Error()})
+			}
+			// InputOffset points to the end of the element. Find where it starts.
+			start := dec.InputOffset() - int64(len(raw))
+			line := lines.at(start)
+			record, err := decodeJSONRecord(raw)
+			if err != nil {
+				errs = append(errs, UsersFileError{Line: line, Message: err.Error()})
+				continue
+			}
+			records = append(records, numberedRecord{line: line, record: record})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, append(errs, UsersFileError{Line: lines.at(dec.InputOffset()), Message: err.Error()})
+		}
+	}
+	return
+}
+
+func parseUsersJSONLines(b []byte) (records []numberedRecord, errs UsersFileErrors) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		record, err := decodeJSONRecord(text)
+		if err != nil {
+			errs = append(errs, UsersFileError{Line: line, Message: err.Error()})
+			continue
+		}
+		records = append(records, numberedRecord{line: line, record: record})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, UsersFileError{Line: 0, Message: err.Error()})
+	}
+	return
+}
+
+func parseUsersText(b []byte) (records []numberedRecord, errs UsersFileErrors) {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		records = append(records, numberedRecord{line: line, record: userRecord{UserName: text}})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, UsersFileError{Line: 0, Message: err.Error()})
+	}
+	return
+}
+
+func decodeJSONRecord(raw []byte) (record userRecord, err error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&record)
+	return
+}
+
+var yamlListItem = regexp.MustCompile(`^(\s*)-(\s|$)`)
+
+func parseUsersYAML(b []byte) (records []numberedRecord, errs UsersFileErrors) {
+	var items []interface{}
+	if err := yaml.Unmarshal(b, &items); err != nil {
+		data := struct {
+			Data []interface{} `yaml:"data"`
+		}{}
+		if err2 := yaml.UnmarshalStrict(b, &data); err2 != nil {
+			return nil, UsersFileErrors{{Line: 1, Message: err2.Error()}}
+		}
+		items = data.Data
+	}
+
+	// yaml.v2 does not expose positions, so lines of list items are found
+	// by the indentation of the first item.
+	itemLines := []int{}
+	indent := -1
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; scanner.Scan(); line++ {
+		m := yamlListItem.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		if indent == -1 {
+			indent = len(m[1])
+		}
+		if len(m[1]) == indent {
+			itemLines = append(itemLines, line)
+		}
+	}
+
+	for i, item := range items {
+		line := 0
+		if i < len(itemLines) {
+			line = itemLines[i]
+		}
+		raw, err := yaml.Marshal(item)
+		if err != nil {
+			errs = append(errs, UsersFileError{Line: line, Message: err.Error()})
+			continue
+		}
+		var record userRecord
+		if err := yaml.UnmarshalStrict(raw, &record); err != nil {
+			errs = append(errs, UsersFileError{Line: line, Message: yamlErrorMessage(err)})
+			continue
+		}
+		records = append(records, numberedRecord{line: line, record: record})
+	}
+	return
+}
+
+var yamlLinePrefix = regexp.MustCompile(`^line \d+: `)
+
+// yamlErrorMessage strips line numbers of re-marshaled item from yaml errors.
+func yamlErrorMessage(err error) string {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err.Error()
+	}
+	messages := []string{}
+	for _, e := range typeErr.Errors {
+		messages = append(messages, yamlLinePrefix.ReplaceAllString(e, ""))
+	}
+	return strings.Join(messages, ", ")
+}
+
+func parseUsersCSV(b []byte) (records []numberedRecord, errs UsersFileErrors) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, UsersFileErrors{{Line: 1, Message: err.Error()}}
+	}
+
+	hasUserName := false
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		header[i] = column
+		switch {
+		case column == "username":
+			hasUserName = true
+		case column == "fullname", column == "groups":
+		case strings.HasPrefix(column, CSVAttributePrefix) && len(column) > len(CSVAttributePrefix):
+		default:
+			errs = append(errs, UsersFileError{Line: 1, Message: fmt.Sprintf("unknown column %q", column)})
+		}
+	}
+	if !hasUserName {
+		errs = append(errs, UsersFileError{Line: 1, Message: "column username is required"})
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// FieldPos panics after a parse error, so the line is taken from the error.
+			if pe, ok := err.(*csv.ParseError); ok {
+				errs = append(errs, UsersFileError{Line: pe.Line, Message: pe.Err.Error()})
+				continue
+			}
+			errs = append(errs, UsersFileError{Line: 0, Message: err.Error()})
+			break
+		}
+		line, _ := r.FieldPos(0)
+
+		var record userRecord
+		for i, value := range row {
+			value = strings.TrimSpace(value)
+			switch column := header[i]; column {
+			case "username":
+				record.UserName = value
+			case "fullname":
+				record.FullName = value
+			case "groups":
+				for _, g := range strings.Split(value, ";") {
+					if g = strings.TrimSpace(g); g != "" {
+						record.Groups = append(record.Groups, g)
+					}
+				}
+			default:
+				if value == "" {
+					continue
+				}
+				if record.Attributes == nil {
+					record.Attributes = make(map[string]string)
+				}
+				record.Attributes[strings.TrimPrefix(column, CSVAttributePrefix)] = value
+			}
+		}
+		records = append(records, numberedRecord{line: line, record: record})
+	}
+	return
+}
+
+func validateUserRecords(records []numberedRecord) (errs UsersFileErrors) {
+	seen := make(map[string]int)
+	for _, r := range records {
+		if err := ValidateEmail(r.record.UserName); err != nil {
+			errs = append(errs, UsersFileError{Line: r.line, Message: err.Error()})
+			continue
+		}
+		name := strings.ToLower(r.record.UserName)
+		if first, ok := seen[name]; ok {
+			errs = append(errs, UsersFileError{
+				Line:    r.line,
+				Message: fmt.Sprintf("duplicate user %v (first seen at line %d)", r.record.UserName, first),
+			})
+			continue
+		}
+		seen[name] = r.line
+	}
+	return
+}
+
+// ValidateEmail checks that `email` is a bare email address which LastPass accepts as username.
+func ValidateEmail(email string) error {
+	if email == "" {
+		return fmt.Errorf("username is empty")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return fmt.Errorf("invalid email %q", email)
+	}
+	return nil
+}
+
+// lineCounter converts a byte offset into a line number.
+type lineCounter struct {
+	starts []int64
+}
+
+func newLineCounter(b []byte) *lineCounter {
+	c := &lineCounter{starts: []int64{0}}
+	for i, ch := range b {
+		if ch == '\n' {
+			c.starts = append(c.starts, int64(i+1))
+		}
+	}
+	return c
+}
+
+func (c *lineCounter) at(offset int64) int {
+	line := 0
+	for line < len(c.starts) && c.starts[line] <= offset {
+		line++
+	}
+	return line
+}
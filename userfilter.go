@@ -0,0 +1,127 @@
+package lpmgt
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UserFilter selects users by conditions such as `group=Sales` or `multifactor!=duo`.
+// All conditions have to match.
+type UserFilter struct {
+	conditions []userCondition
+}
+
+type userCondition struct {
+	key    string
+	value  string
+	negate bool
+}
+
+// userFilterKeys are keys accepted by ParseUserFilter besides `attr.<name>`.
+var userFilterKeys = []string{"username", "fullname", "group", "multifactor", "admin", "disabled", "neverloggedin"}
+
+// ParseUserFilter parses conditions in `key=value` or `key!=value` separated by `,`.
+// `username` and `fullname` accept `*` wildcards, `group` matches any group of the user,
+// `multifactor=none` matches users without MFA and `attr.<name>` matches a custom attribute.
+func ParseUserFilter(exprs ...string) (*UserFilter, error) {
+	f := &UserFilter{}
+	for _, expr := range exprs {
+		for _, term := range strings.Split(expr, ",") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				continue
+			}
+			c := userCondition{}
+			kv := strings.SplitN(term, "!=", 2)
+			if len(kv) == 2 {
+				c.negate = true
+			} else {
+				kv = strings.SplitN(term, "=", 2)
+			}
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("Condition has to be in <key=value> or <key!=value> format: %v", term)
+			}
+			c.key = strings.ToLower(strings.TrimSpace(kv[0]))
+			c.value = strings.TrimSpace(kv[1])
+			if !strings.HasPrefix(c.key, "attr.") && !contains(userFilterKeys, c.key) {
+				return nil, fmt.Errorf("Unknown key %v: choose from %v or attr.<name>", c.key, strings.Join(userFilterKeys, ", "))
+			}
+			switch c.key {
+			case "admin", "disabled", "neverloggedin":
+				if _, err := strconv.ParseBool(c.value); err != nil {
+					return nil, fmt.Errorf("%v has to be true or false: %v", c.key, c.value)
+				}
+			case "username", "fullname":
+				if _, err := filepath.Match(strings.ToLower(c.value), ""); err != nil {
+					return nil, fmt.Errorf("Invalid pattern %v: %v", c.value, err)
+				}
+			}
+			f.conditions = append(f.conditions, c)
+		}
+	}
+	if len(f.conditions) == 0 {
+		return nil, fmt.Errorf("No condition is given")
+	}
+	return f, nil
+}
+
+// Matches reports whether `u` satisfies all conditions.
+func (f *UserFilter) Matches(u User) bool {
+	for _, c := range f.conditions {
+		if c.matches(u) == c.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns users satisfying all conditions.
+func (f *UserFilter) Select(users []User) []User {
+	selected := []User{}
+	for _, u := range users {
+		if f.Matches(u) {
+			selected = append(selected, u)
+		}
+	}
+	return selected
+}
+
+func (c userCondition) matches(u User) bool {
+	switch c.key {
+	case "username":
+		return matchesPattern(c.value, u.UserName)
+	case "fullname":
+		return matchesPattern(c.value, u.FullName)
+	case "group":
+		for _, g := range u.Groups {
+			if strings.EqualFold(g, c.value) {
+				return true
+			}
+		}
+		return false
+	case "multifactor":
+		if strings.EqualFold(c.value, "none") {
+			return u.Multifactor == ""
+		}
+		return strings.EqualFold(u.Multifactor, c.value)
+	case "admin":
+		b, _ := strconv.ParseBool(c.value)
+		return u.IsAdmin == b
+	case "disabled":
+		b, _ := strconv.ParseBool(c.value)
+		return u.Disabled == b
+	case "neverloggedin":
+		b, _ := strconv.ParseBool(c.value)
+		return u.NeverLoggedIn == b
+	default:
+		return u.Attributes[strings.TrimPrefix(c.key, "attr.")] == c.value
+	}
+}
+
+// matchesPattern matches `s` against `pattern` with `*` wildcards, ignoring case.
+func matchesPattern(pattern, s string) bool {
+	ok, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(s))
+	return ok
+}
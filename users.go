@@ -1,9 +1,11 @@
 package lpmgt
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"net/http"
+	"strings"
 )
 
 // UserService is a service class that sends a request to LastPass provisioning API.
@@ -110,22 +112,173 @@ func (s *UserService) GetUserData(userName string) (user User, err error) {
 
 // BatchAdd - add users.
 func (s *UserService) BatchAdd(users []User) error {
+	status, err := s.batchAdd(users)
+	if err != nil {
+		return err
+	}
+	return status.Error()
+}
+
+func (s *UserService) batchAdd(users []User) (*APIResultStatusForBatch, error) {
 	s.command = "batchadd"
 	s.data = users
 	res, err := s.doRequest()
-	status := &APIResultStatus{}
+	if err != nil {
+		return nil, err
+	}
+	status := &APIResultStatusForBatch{}
 	err = JSONBodyDecoder(res, status)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	 return status.Error()
+	return status, nil
+}
+
+// DefaultBatchSize is the number of users sent in a single batch request.
+const DefaultBatchSize = 100
+
+// BatchResult is an outcome of an operation for an user in a batch.
+type BatchResult string
+
+const (
+	// Created means the user was created.
+	Created BatchResult = "created"
+	// Updated means the existing user was updated.
+	Updated BatchResult = "updated"
+	// Skipped means nothing was sent for the user.
+	Skipped BatchResult = "skipped"
+	// Failed means LastPass rejected the user or the request itself failed.
+	Failed BatchResult = "failed"
+	// Done means an operation other than creation or update succeeded.
+	Done BatchResult = "done"
+)
+
+// UserResult is an outcome of an operation for an user.
+type UserResult struct {
+	UserName string      `json:"username"`
+	Result   BatchResult `json:"result"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+// BatchAddOptions configures BatchAddInChunks.
+type BatchAddOptions struct {
+	// ChunkSize is the maximum number of users in a request. DefaultBatchSize is used if it's not positive.
+	ChunkSize int
+	// Upsert updates users who already exist instead of skipping them.
+	Upsert bool
+}
+
+// BatchAddInChunks adds users by `batchadd` split into chunks and reports outcome of each user.
+// Users who already exist are skipped unless opts.Upsert is set.
+// A failed chunk does not stop the rest. Its users are reported as Failed.
+func (s *UserService) BatchAddInChunks(users []User, opts BatchAddOptions) ([]UserResult, error) {
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultBatchSize
+	}
+
+	results, err := s.ClassifyForBatchAdd(users, opts.Upsert)
+	if err != nil {
+		return nil, err
+	}
+	sending := []int{}
+	for i, r := range results {
+		if r.Result != Skipped {
+			sending = append(sending, i)
+		}
+	}
+
+	for start := 0; start < len(sending); start += chunkSize {
+		end := start + chunkSize
+		if end > len(sending) {
+			end = len(sending)
+		}
+		chunk := []User{}
+		expected := []UserResult{}
+		for _, i := range sending[start:end] {
+			chunk = append(chunk, users[i])
+			expected = append(expected, results[i])
+		}
+		for j, r := range s.BatchAddChunk(chunk, expected) {
+			results[sending[start+j]] = r
+		}
+	}
+	return results, nil
+}
+
+// ClassifyForBatchAdd returns Created for new users, and either Skipped or Updated for existing ones.
+func (s *UserService) ClassifyForBatchAdd(users []User, upsert bool) ([]UserResult, error) {
+	existingUsers, err := s.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, u := range existingUsers {
+		exists[strings.ToLower(u.UserName)] = true
+	}
+
+	results := make([]UserResult, len(users))
+	for i, u := range users {
+		results[i] = UserResult{UserName: u.UserName, Result: Created}
+		if exists[strings.ToLower(u.UserName)] {
+			if !upsert {
+				results[i].Result = Skipped
+				results[i].Reason = "already exists"
+				continue
+			}
+			results[i].Result = Updated
+		}
+	}
+	return results, nil
+}
+
+// BatchAddChunk sends users in a single `batchadd` and reports outcome of each user.
+// `expected` is the result of each user when LastPass accepts it.
+func (s *UserService) BatchAddChunk(users []User, expected []UserResult) []UserResult {
+	results := append([]UserResult{}, expected...)
+	status, err := s.batchAdd(users)
+	for i, u := range users {
+		switch {
+		case err != nil:
+			results[i].Result = Failed
+			results[i].Reason = err.Error()
+		case status.Status == "FAIL":
+			results[i].Result = Failed
+			results[i].Reason = strings.Join(status.Errors, ", ")
+		default:
+			if reason := status.ErrorFor(u.UserName); reason != "" {
+				results[i].Result = Failed
+				results[i].Reason = reason
+			}
+		}
+	}
+	return results
 }
 
 // UpdateUser updates user's info.
+// Attributes which is empty but not nil is sent as an empty object to remove all of them.
 func (s *UserService) UpdateUser(user User) error {
 	s.command = "batchadd"
+	// Last login is returned by getuserdata but is not a field to be updated.
+	user.LastLogin = ""
 	s.data = user
+	if user.Attributes != nil && len(user.Attributes) == 0 {
+		// omitempty would drop the empty map and leave removed attributes in place.
+		b, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		data := map[string]json.RawMessage{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return err
+		}
+		data["attribs"] = json.RawMessage("{}")
+		s.data = data
+	}
 	res, err := s.doRequest()
+	if err != nil {
+		return err
+	}
 	status := &APIResultStatus{}
 	err = JSONBodyDecoder(res, status)
 	if err != nil {
@@ -147,6 +300,25 @@ func (s *UserService) DeleteUser(name string, mode DeactivationMode) error {
 		DeleteAction int    `json:"deleteaction"`
 	}{UserName: name, DeleteAction: int(mode)}
 	res, err := s.doRequest()
+	if err != nil {
+		return err
+	}
+	status := &APIResultStatus{}
+	err = JSONBodyDecoder(res, status)
+	if err != nil {
+		return err
+	}
+	return status.Error()
+}
+
+// ReactivateUser reactivates a deactivated user.
+func (s *UserService) ReactivateUser(name string) error {
+	s.command = "reactivateuser"
+	s.data = User{UserName: name}
+	res, err := s.doRequest()
+	if err != nil {
+		return err
+	}
 	status := &APIResultStatus{}
 	err = JSONBodyDecoder(res, status)
 	if err != nil {
@@ -306,9 +478,68 @@ func (s *UserService) ResetPassword(username string) (*APIResultStatusForPasswor
     ]
 }
 */
-//func (s *UserService) ChangeGroupsMembership(groups []TransferringUser) (*http.Response, error) {
-//	return .DoRequest("batchchangegrp", groups)
-//}
+func (s *UserService) ChangeGroupsMembership(groups []TransferringUser) (*APIResultStatusForBatch, error) {
+	s.command = "batchchangegrp"
+	s.data = groups
+	res, err := s.doRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &APIResultStatusForBatch{}
+	err = JSONBodyDecoder(res, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, status.Error()
+}
+
+// TransferringUser is a change of groups of an user in batchchangegrp.
+type TransferringUser struct {
+	UserName string   `json:"username"`
+	Add      []string `json:"add,omitempty"`
+	Del      []string `json:"del,omitempty"`
+}
+
+// Reverse returns the change which undoes `t`.
+func (t TransferringUser) Reverse() TransferringUser {
+	return TransferringUser{UserName: t.UserName, Add: t.Del, Del: t.Add}
+}
+
+// ChangeGroupsMembershipInChunks sends changes by `batchchangegrp` split into chunks
+// and reports outcome of each user. A failed chunk does not stop the rest.
+func (s *UserService) ChangeGroupsMembershipInChunks(changes []TransferringUser, chunkSize int) []UserResult {
+	if chunkSize <= 0 {
+		chunkSize = DefaultBatchSize
+	}
+
+	results := make([]UserResult, len(changes))
+	for start := 0; start < len(changes); start += chunkSize {
+		end := start + chunkSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+
+		status, err := s.ChangeGroupsMembership(changes[start:end])
+		for i := start; i < end; i++ {
+			results[i] = UserResult{UserName: changes[i].UserName, Result: Updated}
+			switch {
+			case status == nil:
+				results[i].Result = Failed
+				results[i].Reason = err.Error()
+			case status.Status == "FAIL":
+				results[i].Result = Failed
+				results[i].Reason = strings.Join(status.Errors, ", ")
+			default:
+				if reason := status.ErrorFor(changes[i].UserName); reason != "" {
+					results[i].Result = Failed
+					results[i].Reason = reason
+				}
+			}
+		}
+	}
+	return results
+}
 
 // NewUserService creates a new UserService
 func NewUserService(client *LastPassClient) (s *UserService) {
@@ -326,7 +557,7 @@ type User struct {
 	MasterPasswordStrength string   `json:"mpstrength,omitempty"`
 	Created                string   `json:"created,omitempty"`
 	LastPasswordChange     string   `json:"last_pw_change,omitempty"`
-	LastLogin              string   `json:"lastlogin,omitempty"`
+	LastLogin              string   `json:"last_login,omitempty"`
 	Disabled               bool     `json:"disabled,omitempty"`
 	NeverLoggedIn          bool     `json:"neverloggedin,omitempty"`
 	LinkedAccount          string   `json:"linked,omitempty"`
@@ -344,6 +575,8 @@ type User struct {
 	LastPwChange         string   `json:"last_pw_change,omitempty"`
 	Mpstrength           string   `json:"mpstrength,omitempty"`
 	Multifactor          string   `json:"multifactor,omitempty"`
+	Attributes           map[string]string `json:"attribs,omitempty"`
+	PasswordResetRequired bool             `json:"password_reset_required,omitempty"`
 }
 
 type users struct {
@@ -361,6 +594,26 @@ func (u *User) contains(users []string) bool {
 	return false
 }
 
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupMembers maps each group name to its members.
+func GroupMembers(users []User) map[string][]User {
+	groups := make(map[string][]User)
+	for _, u := range users {
+		for _, g := range u.Groups {
+			groups[g] = append(groups[g], u)
+		}
+	}
+	return groups
+}
+
 func (us *users) getUsers() []User {
 	users := []User{}
 	for _, user := range us.Users {